@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcrypt 计算成本，默认值 10 在普通服务器上单次约 50~100ms
+const passwordCost = bcrypt.DefaultCost
+
+// MaxPasswordBytes bcrypt 只接受不超过 72 字节的密码
+const MaxPasswordBytes = 72
+
+// dummyHash 首次使用时生成，供 CheckDummyPassword 比较
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("dummy-password"), passwordCost)
+	return hash
+})
+
+// HashPassword 使用 bcrypt 生成密码哈希
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// IsHashed 判断数据库中的密码是否已是 bcrypt 哈希，早期数据为明文
+func IsHashed(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$")
+}
+
+// CheckPassword 校验密码，兼容旧的明文记录。
+// needsRehash 为 true 表示校验通过但存储的是明文或成本过低，调用方应重新哈希后写回。
+func CheckPassword(stored, password string) (ok bool, needsRehash bool) {
+	if !IsHashed(stored) {
+		ok = subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+		return ok, ok
+	}
+	if bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) != nil {
+		return false, false
+	}
+	cost, err := bcrypt.Cost([]byte(stored))
+	return true, err != nil || cost < passwordCost
+}
+
+// CheckDummyPassword 与固定哈希做一次 bcrypt 比较并丢弃结果。
+// 用户不存在时调用，使耗时与真实校验一致，避免通过响应时间探测用户名是否存在
+func CheckDummyPassword(password string) {
+	_ = bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+}
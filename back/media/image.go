@@ -0,0 +1,152 @@
+package media
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // 注册 GIF 解码器
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+)
+
+// ErrTooLarge 文件超过大小上限或图片像素过多
+var ErrTooLarge = errors.New("文件过大")
+
+// ErrUnsupportedType 不是支持的图片格式
+var ErrUnsupportedType = errors.New("仅支持 JPEG、PNG、GIF 图片")
+
+// ErrInvalidImage 图片内容损坏，无法解码
+var ErrInvalidImage = errors.New("图片无法解析")
+
+// 解码前按图片头部校验的像素上限，防止小文件解压出超大图片
+const maxPixels = 40_000_000
+
+// 重新编码 JPEG 的质量
+const jpegQuality = 85
+
+// Options 图片处理参数
+type Options struct {
+	MaxBytes  int64 // 原始文件大小上限
+	ThumbSize int   // 缩略图边长，缩略图为居中裁剪后的正方形
+}
+
+// Image 处理后的图片
+type Image struct {
+	Data  []byte // 重新编码后的图片，不再包含 EXIF 等元数据
+	Thumb []byte // JPEG 缩略图
+	Ext   string // Data 的扩展名，".jpg" 或 ".png"
+	Hash  string // Data 的 sha256（十六进制），用作文件名
+}
+
+// Process 读取上传的图片：按文件内容识别类型，只接受 JPEG、PNG、GIF，
+// 解码后重新编码并生成缩略图。GIF 只保留第一帧，转为 PNG
+func Process(r io.Reader, opts Options) (*Image, error) {
+	raw, err := io.ReadAll(io.LimitReader(r, opts.MaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(raw)) > opts.MaxBytes {
+		return nil, ErrTooLarge
+	}
+	switch http.DetectContentType(raw) {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return nil, ErrUnsupportedType
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, ErrInvalidImage
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, ErrTooLarge
+	}
+	src, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+
+	img := &Image{}
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		img.Ext = ".jpg"
+		err = jpeg.Encode(&buf, src, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		img.Ext = ".png"
+		err = png.Encode(&buf, src)
+	}
+	if err != nil {
+		return nil, err
+	}
+	img.Data = buf.Bytes()
+	sum := sha256.Sum256(img.Data)
+	img.Hash = hex.EncodeToString(sum[:])
+
+	var thumb bytes.Buffer
+	if err := jpeg.Encode(&thumb, Thumbnail(src, opts.ThumbSize), &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, err
+	}
+	img.Thumb = thumb.Bytes()
+	return img, nil
+}
+
+// Thumbnail 把图片居中裁剪为正方形后缩放到 size×size。
+// 缩小时对每个目标像素覆盖的源像素取平均，放大时取最近的源像素；透明部分以白色填充
+func Thumbnail(src image.Image, size int) *image.RGBA {
+	b := src.Bounds()
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+	crop := image.Rect(0, 0, side, side)
+	// 先铺白底再绘制，统一为 RGBA 后直接读写 Pix
+	sq := image.NewRGBA(crop)
+	draw.Draw(sq, crop, image.NewUniform(color.White), image.Point{}, draw.Src)
+	offset := image.Pt(b.Min.X+(b.Dx()-side)/2, b.Min.Y+(b.Dy()-side)/2)
+	draw.Draw(sq, crop, src, offset, draw.Over)
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		y0, y1 := span(y, size, side)
+		for x := 0; x < size; x++ {
+			x0, x1 := span(x, size, side)
+			var r, g, bl, a, n int
+			for sy := y0; sy < y1; sy++ {
+				i := sq.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					r += int(sq.Pix[i])
+					g += int(sq.Pix[i+1])
+					bl += int(sq.Pix[i+2])
+					a += int(sq.Pix[i+3])
+					n++
+					i += 4
+				}
+			}
+			j := dst.PixOffset(x, y)
+			dst.Pix[j] = uint8(r / n)
+			dst.Pix[j+1] = uint8(g / n)
+			dst.Pix[j+2] = uint8(bl / n)
+			dst.Pix[j+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// span 返回目标坐标 i（共 size 个）对应的源坐标区间 [lo, hi)，至少包含一个源像素
+func span(i, size, side int) (int, int) {
+	lo := i * side / size
+	hi := (i + 1) * side / size
+	if hi <= lo {
+		hi = lo + 1
+	}
+	return lo, hi
+}
@@ -0,0 +1,145 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func fixture(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+var testOptions = Options{MaxBytes: 1 << 20, ThumbSize: 4}
+
+func TestProcessRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		opts    Options
+		wantErr error
+	}{
+		{"svg disguised as png", "svg.png", testOptions, ErrUnsupportedType},
+		{"html disguised as png", "html.png", testOptions, ErrUnsupportedType},
+		{"webp", "image.webp", testOptions, ErrUnsupportedType},
+		{"gif header followed by html", "polyglot.gif", testOptions, ErrInvalidImage},
+		{"truncated png", "truncated.png", testOptions, ErrInvalidImage},
+		{"too many pixels", "huge.png", testOptions, ErrTooLarge},
+		{"file over size limit", "trailing.png", Options{MaxBytes: 100, ThumbSize: 4}, ErrTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := Process(bytes.NewReader(fixture(t, tt.fixture)), tt.opts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Process = %v, %v, want %v", img, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProcessReencodes(t *testing.T) {
+	tests := []struct {
+		fixture string
+		ext     string
+		decode  func([]byte) (image.Image, error)
+		size    image.Point
+	}{
+		{"trailing.png", ".png", func(b []byte) (image.Image, error) { return png.Decode(bytes.NewReader(b)) }, image.Pt(4, 2)},
+		{"trailing.jpg", ".jpg", func(b []byte) (image.Image, error) { return jpeg.Decode(bytes.NewReader(b)) }, image.Pt(4, 2)},
+		{"animated.gif", ".png", func(b []byte) (image.Image, error) { return png.Decode(bytes.NewReader(b)) }, image.Pt(3, 3)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			raw := fixture(t, tt.fixture)
+			img, err := Process(bytes.NewReader(raw), testOptions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if img.Ext != tt.ext {
+				t.Errorf("Ext = %s, want %s", img.Ext, tt.ext)
+			}
+			// 原文件中的注释段和结束标记之后的数据不会出现在重新编码的结果中
+			for _, marker := range []string{"TRAILING-PAYLOAD", "SECRET-METADATA", "<?php"} {
+				if bytes.Contains(img.Data, []byte(marker)) || bytes.Contains(img.Thumb, []byte(marker)) {
+					t.Errorf("output still contains %q", marker)
+				}
+			}
+			if bytes.Equal(img.Data, raw) {
+				t.Error("image not re-encoded")
+			}
+			decoded, err := tt.decode(img.Data)
+			if err != nil {
+				t.Fatalf("decode output: %v", err)
+			}
+			if got := decoded.Bounds().Size(); got != tt.size {
+				t.Errorf("size = %v, want %v", got, tt.size)
+			}
+			thumb, err := jpeg.Decode(bytes.NewReader(img.Thumb))
+			if err != nil {
+				t.Fatalf("decode thumbnail: %v", err)
+			}
+			if got := thumb.Bounds().Size(); got != image.Pt(testOptions.ThumbSize, testOptions.ThumbSize) {
+				t.Errorf("thumbnail size = %v", got)
+			}
+			if len(img.Hash) != 64 {
+				t.Errorf("Hash = %q", img.Hash)
+			}
+		})
+	}
+
+	// 相同内容得到相同的文件名
+	a, _ := Process(bytes.NewReader(fixture(t, "trailing.png")), testOptions)
+	b, _ := Process(bytes.NewReader(fixture(t, "trailing.png")), testOptions)
+	if a.Hash != b.Hash {
+		t.Error("hash differs for identical input")
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	// 6x2：左 2 列红、中 2 列绿、右 2 列透明，居中裁剪后只剩中间的绿色
+	src := image.NewNRGBA(image.Rect(0, 0, 6, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 6; x++ {
+			switch {
+			case x < 2:
+				src.Set(x, y, color.NRGBA{255, 0, 0, 255})
+			case x < 4:
+				src.Set(x, y, color.NRGBA{0, 255, 0, 255})
+			}
+		}
+	}
+	if got := Thumbnail(src, 1).RGBAAt(0, 0); got != (color.RGBA{0, 255, 0, 255}) {
+		t.Errorf("center crop = %v, want green", got)
+	}
+
+	// 缩小时取平均；透明部分以白色填充
+	half := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	half.Set(0, 0, color.NRGBA{0, 0, 0, 255})
+	half.Set(0, 1, color.NRGBA{0, 0, 0, 255})
+	if got := Thumbnail(half, 1).RGBAAt(0, 0); got != (color.RGBA{127, 127, 127, 255}) {
+		t.Errorf("average = %v, want gray", got)
+	}
+
+	// 放大时取最近的源像素
+	up := Thumbnail(half, 4)
+	if up.Bounds().Dx() != 4 || up.RGBAAt(0, 3) != (color.RGBA{0, 0, 0, 255}) || up.RGBAAt(3, 0) != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("upscale = %v %v", up.RGBAAt(0, 3), up.RGBAAt(3, 0))
+	}
+
+	// 非零原点的图片
+	sub := src.SubImage(image.Rect(2, 0, 4, 2))
+	if got := Thumbnail(sub, 2).RGBAAt(1, 1); got != (color.RGBA{0, 255, 0, 255}) {
+		t.Errorf("sub image = %v, want green", got)
+	}
+}
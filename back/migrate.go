@@ -0,0 +1,110 @@
+package main
+
+import (
+	"back/migrations"
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"log"
+	"strconv"
+	"time"
+)
+
+const migrateUsage = `用法: go run . [-config 配置文件] migrate <命令>
+
+命令:
+  up            执行全部未执行的迁移
+  down [n|all]  回滚最近 n 个迁移（默认 1）
+  status        查看迁移执行状态
+  seed          写入本地开发测试数据（products 表为空时）
+  force <版本>  将该版本及之前的迁移标记为已执行（接管旧脚本建好的数据库）`
+
+// runMigrate 执行 migrate 子命令
+func runMigrate(pool *pgxpool.Pool, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("缺少命令\n%s", migrateUsage)
+	}
+	ctx := context.Background()
+	m, err := migrations.New(pool)
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "up":
+		done, err := m.Up(ctx)
+		for _, mig := range done {
+			log.Printf("已执行 %d_%s", mig.Version, mig.Name)
+		}
+		if err == nil && len(done) == 0 {
+			log.Printf("数据库结构已是最新")
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if args[1] == "all" {
+				steps = int(^uint(0) >> 1)
+			} else if steps, err = strconv.Atoi(args[1]); err != nil || steps <= 0 {
+				return fmt.Errorf("回滚数量不合法: %s", args[1])
+			}
+		}
+		done, err := m.Down(ctx, steps)
+		for _, mig := range done {
+			log.Printf("已回滚 %d_%s", mig.Version, mig.Name)
+		}
+		return err
+	case "status":
+		list, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, st := range list {
+			state := "未执行"
+			if st.AppliedAt != nil {
+				state = "已执行于 " + st.AppliedAt.Format(time.DateTime)
+			}
+			fmt.Printf("%04d_%-30s %s\n", st.Version, st.Name, state)
+		}
+		return nil
+	case "seed":
+		if err := m.CheckCurrent(ctx); err != nil {
+			return err
+		}
+		seeded, err := m.Seed(ctx)
+		if err == nil && !seeded {
+			log.Printf("products 表已有数据，跳过")
+		}
+		return err
+	case "force":
+		if len(args) < 2 {
+			return fmt.Errorf("缺少版本号\n%s", migrateUsage)
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("版本号不合法: %s", args[1])
+		}
+		return m.Force(ctx, version)
+	default:
+		return fmt.Errorf("未知命令 %q\n%s", args[0], migrateUsage)
+	}
+}
+
+// ensureSchema 服务启动前检查数据库结构，按配置自动迁移或拒绝启动
+func ensureSchema(pool *pgxpool.Pool, autoMigrate bool) error {
+	ctx := context.Background()
+	m, err := migrations.New(pool)
+	if err != nil {
+		return err
+	}
+	if autoMigrate {
+		done, err := m.Up(ctx)
+		for _, mig := range done {
+			log.Printf("已执行迁移 %d_%s", mig.Version, mig.Name)
+		}
+		return err
+	}
+	if err := m.CheckCurrent(ctx); err != nil {
+		return fmt.Errorf("%w，请先执行 go run . migrate up", err)
+	}
+	return nil
+}
@@ -1,142 +1,32 @@
-DROP TABLE IF EXISTS product_reviews CASCADE;
-DROP TABLE IF EXISTS cart CASCADE;
-DROP TABLE IF EXISTS orders CASCADE;
-DROP TABLE IF EXISTS product_images CASCADE;
-DROP TABLE IF EXISTS product_models CASCADE;
-DROP TABLE IF EXISTS products CASCADE;
-DROP TABLE IF EXISTS users CASCADE;
-DROP TABLE IF EXISTS order_items CASCADE;
-
-DROP SEQUENCE IF EXISTS users_id_seq CASCADE;
-DROP SEQUENCE IF EXISTS products_id_seq CASCADE;
-DROP SEQUENCE IF EXISTS product_models_id_seq CASCADE;
-DROP SEQUENCE IF EXISTS product_images_id_seq CASCADE;
-DROP SEQUENCE IF EXISTS orders_id_seq CASCADE;
-DROP SEQUENCE IF EXISTS cart_id_seq CASCADE;
-DROP SEQUENCE IF EXISTS product_reviews_id_seq CASCADE;
-DROP SEQUENCE IF EXISTS order_items_id_seq CASCADE;
-
-CREATE SEQUENCE users_id_seq;
-CREATE TABLE users (
-  id int4 NOT NULL DEFAULT nextval('users_id_seq'::regclass),
-  username varchar(64) NOT NULL UNIQUE,
-  password varchar(128) NOT NULL,
-  address text,
-  avatar text,
-  nickname text,
-  PRIMARY KEY (id)
-);
-
-CREATE SEQUENCE products_id_seq;
-CREATE TABLE products (
-  id int4 NOT NULL DEFAULT nextval('products_id_seq'::regclass),
-  title varchar(255) NOT NULL,
-  category varchar(100),
-  description text,
-  PRIMARY KEY (id)
-);
-
-CREATE SEQUENCE product_models_id_seq;
-CREATE TABLE product_models (
-  id int4 NOT NULL DEFAULT nextval('product_models_id_seq'::regclass),
-  product_id int4 NOT NULL,
-  model_name varchar(100) NOT NULL,
-  price numeric(10,2) NOT NULL,
-  stock int4 NOT NULL DEFAULT 0,
-  PRIMARY KEY (id)
-);
-
-CREATE SEQUENCE product_images_id_seq;
-CREATE TABLE product_images (
-  id int4 NOT NULL DEFAULT nextval('product_images_id_seq'::regclass),
-  product_id int4 NOT NULL,
-  url text NOT NULL,
-  PRIMARY KEY (id)
-);
-
-CREATE SEQUENCE orders_id_seq;
-CREATE TABLE orders (
-  id int4 NOT NULL DEFAULT nextval('orders_id_seq'::regclass),
-  user_id int4 NOT NULL,
-  status varchar(20) NOT NULL CHECK (status IN (
-    'pending', 'toship', 'toreceive', 'toreview', 'refund'
-  )),
-  total_price numeric(10,2) NOT NULL,
-  address text,
-  created_at timestamp(6) DEFAULT CURRENT_TIMESTAMP,
-  updated_at timestamp(6) DEFAULT CURRENT_TIMESTAMP,
-  PRIMARY KEY (id)
-);
-
-CREATE SEQUENCE order_items_id_seq;
-CREATE TABLE order_items (
-  id int4 NOT NULL DEFAULT nextval('order_items_id_seq'::regclass),
-  order_id int4 NOT NULL,
-  product_id int4 NOT NULL,
-  model_id int4 NOT NULL,
-  quantity int4 NOT NULL DEFAULT 1,
-  price numeric(10,2) NOT NULL,
-  PRIMARY KEY (id)
-);
-
-CREATE SEQUENCE cart_id_seq;
-CREATE TABLE cart (
-  id int4 NOT NULL DEFAULT nextval('cart_id_seq'::regclass),
-  user_id int4 NOT NULL,
-  product_id int4 NOT NULL,
-  model_id int4 NOT NULL,
-  quantity int4 NOT NULL DEFAULT 1 CHECK (quantity > 0),
-  created_at timestamp(6) DEFAULT CURRENT_TIMESTAMP,
-  updated_at timestamp(6) DEFAULT CURRENT_TIMESTAMP,
-  PRIMARY KEY (id),
-  UNIQUE (user_id, product_id, model_id)
-);
-
-CREATE SEQUENCE product_reviews_id_seq;
-CREATE TABLE product_reviews (
-  id int4 NOT NULL DEFAULT nextval('product_reviews_id_seq'::regclass),
-  product_id int4 NOT NULL,
-  model_id int4 NOT NULL,
-  username varchar(64) NOT NULL,
-  rating int4 NOT NULL CHECK (rating >= 1 AND rating <= 5),
-  content text,
-  created_at timestamp(6) DEFAULT CURRENT_TIMESTAMP,
-  PRIMARY KEY (id)
-);
-
--- 添加外键约束
-ALTER TABLE product_models ADD CONSTRAINT fk_product_models_product_id FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE;
-ALTER TABLE product_images ADD CONSTRAINT fk_product_images_product_id FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE;
-ALTER TABLE orders ADD CONSTRAINT fk_orders_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
-ALTER TABLE order_items ADD CONSTRAINT fk_order_items_order_id FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE;
-ALTER TABLE order_items ADD CONSTRAINT fk_order_items_product_id FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE;
-ALTER TABLE order_items ADD CONSTRAINT fk_order_items_model_id FOREIGN KEY (model_id) REFERENCES product_models(id) ON DELETE CASCADE;
-ALTER TABLE cart ADD CONSTRAINT fk_cart_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
-ALTER TABLE cart ADD CONSTRAINT fk_cart_product_id FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE;
-ALTER TABLE cart ADD CONSTRAINT fk_cart_model_id FOREIGN KEY (model_id) REFERENCES product_models(id) ON DELETE CASCADE;
-ALTER TABLE product_reviews ADD CONSTRAINT fk_product_reviews_product_id FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE;
-ALTER TABLE product_reviews ADD CONSTRAINT fk_product_reviews_model_id FOREIGN KEY (model_id) REFERENCES product_models(id) ON DELETE CASCADE;
-
--- 插入测试数据
-INSERT INTO users (username, password, nickname) VALUES ('admin', '123456', 'admin');
-
-INSERT INTO products (title, category, description) VALUES 
-('iPhone 15 Pro', '手机', '苹果最新旗舰手机，搭载A17 Pro芯片'),
-('MacBook Pro', '电脑', '苹果专业级笔记本电脑'),
-('AirPods Pro', '耳机', '苹果无线降噪耳机'),
-('小米 14', '手机', '小米新一代旗舰，徕卡影像'),
-('华为 Mate60 Pro', '手机', '华为自研芯片，超强续航'),
-('ThinkPad X1 Carbon', '电脑', '商务轻薄本，经典耐用'),
-('戴尔 XPS 13', '电脑', '高端超极本，窄边框设计'),
-('索尼 WH-1000XM5', '耳机', '顶级降噪耳机，舒适佩戴'),
-('三星 Galaxy S24', '手机', '三星旗舰，超感屏'),
-('荣耀 Magic6', '手机', '荣耀高端旗舰，超长续航'),
-('联想拯救者 Y9000P', '电脑', '高性能游戏本'),
-('JBL TUNE 230NC', '耳机', 'JBL主动降噪耳机'),
-('vivo X100 Pro', '手机', '蔡司影像，旗舰体验'),
-('OPPO Find X7', '手机', '超光影潜望长焦'),
-('华硕灵耀14', '电脑', '轻薄便携，OLED屏'),
-('Beats Studio Buds', '耳机', 'Beats无线耳机，潮流之选');
+-- 本地开发用测试数据，仅在 products 表为空时通过 migrate seed 写入
+INSERT INTO users (username, password, nickname, role) VALUES ('admin', '123456', 'admin', 'admin');
+
+INSERT INTO categories (id, parent_id, name, slug, sort_order) VALUES
+(1, NULL, '数码电子', 'digital', 1),
+(2, 1, '手机', 'phones', 1),
+(3, 1, '电脑', 'computers', 2),
+(4, 3, '笔记本', 'laptops', 1),
+(5, 1, '影音', 'audio', 3),
+(6, 5, '耳机', 'headphones', 1);
+SELECT setval('categories_id_seq', (SELECT MAX(id) FROM categories));
+
+INSERT INTO products (title, category_id, description) VALUES 
+('iPhone 15 Pro', 2, '苹果最新旗舰手机，搭载A17 Pro芯片'),
+('MacBook Pro', 4, '苹果专业级笔记本电脑'),
+('AirPods Pro', 6, '苹果无线降噪耳机'),
+('小米 14', 2, '小米新一代旗舰，徕卡影像'),
+('华为 Mate60 Pro', 2, '华为自研芯片，超强续航'),
+('ThinkPad X1 Carbon', 4, '商务轻薄本，经典耐用'),
+('戴尔 XPS 13', 4, '高端超极本，窄边框设计'),
+('索尼 WH-1000XM5', 6, '顶级降噪耳机，舒适佩戴'),
+('三星 Galaxy S24', 2, '三星旗舰，超感屏'),
+('荣耀 Magic6', 2, '荣耀高端旗舰，超长续航'),
+('联想拯救者 Y9000P', 4, '高性能游戏本'),
+('JBL TUNE 230NC', 6, 'JBL主动降噪耳机'),
+('vivo X100 Pro', 2, '蔡司影像，旗舰体验'),
+('OPPO Find X7', 2, '超光影潜望长焦'),
+('华硕灵耀14', 4, '轻薄便携，OLED屏'),
+('Beats Studio Buds', 6, 'Beats无线耳机，潮流之选');
 
 INSERT INTO product_models (product_id, model_name, price, stock) VALUES 
 (1, '128GB 深空黑色', 7999.00, 50),
@@ -322,3 +212,8 @@ INSERT INTO product_reviews (product_id, model_id, username, rating, content) VA
 (16, 41, 'irene', 4, '红色款很亮眼，音质好'),
 (16, 42, 'jack', 5, '黑金限量版很酷，低音澎湃'),
 (16, 41, 'karen', 4, '佩戴舒适，续航不错');
+
+-- 测试评价直接写入 product_reviews，需要同步商品统计
+INSERT INTO product_stats (product_id, review_count, rating_sum)
+SELECT product_id, COUNT(*), SUM(rating) FROM product_reviews GROUP BY product_id
+ON CONFLICT (product_id) DO UPDATE SET review_count = EXCLUDED.review_count, rating_sum = EXCLUDED.rating_sum;
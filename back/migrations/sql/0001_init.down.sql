@@ -0,0 +1,21 @@
+-- 回滚初始表结构（会删除全部业务数据）
+
+DROP TABLE IF EXISTS product_reviews CASCADE;
+DROP TABLE IF EXISTS cart CASCADE;
+DROP TABLE IF EXISTS orders CASCADE;
+DROP TABLE IF EXISTS product_images CASCADE;
+DROP TABLE IF EXISTS product_models CASCADE;
+DROP TABLE IF EXISTS products CASCADE;
+DROP TABLE IF EXISTS users CASCADE;
+DROP TABLE IF EXISTS order_items CASCADE;
+DROP TABLE IF EXISTS order_status_history CASCADE;
+
+DROP SEQUENCE IF EXISTS users_id_seq CASCADE;
+DROP SEQUENCE IF EXISTS products_id_seq CASCADE;
+DROP SEQUENCE IF EXISTS product_models_id_seq CASCADE;
+DROP SEQUENCE IF EXISTS product_images_id_seq CASCADE;
+DROP SEQUENCE IF EXISTS orders_id_seq CASCADE;
+DROP SEQUENCE IF EXISTS cart_id_seq CASCADE;
+DROP SEQUENCE IF EXISTS product_reviews_id_seq CASCADE;
+DROP SEQUENCE IF EXISTS order_items_id_seq CASCADE;
+DROP SEQUENCE IF EXISTS order_status_history_id_seq CASCADE;
@@ -0,0 +1,122 @@
+-- 初始表结构，对应原 postgreSQL/reset_db_with_data.sql 中的建表部分
+
+CREATE SEQUENCE users_id_seq;
+CREATE TABLE users (
+  id int4 NOT NULL DEFAULT nextval('users_id_seq'::regclass),
+  username varchar(64) NOT NULL UNIQUE,
+  password varchar(128) NOT NULL,
+  address text,
+  avatar text,
+  nickname text,
+  role varchar(20) NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'staff', 'admin')),
+  PRIMARY KEY (id)
+);
+
+CREATE SEQUENCE products_id_seq;
+CREATE TABLE products (
+  id int4 NOT NULL DEFAULT nextval('products_id_seq'::regclass),
+  title varchar(255) NOT NULL,
+  category varchar(100),
+  description text,
+  PRIMARY KEY (id)
+);
+
+CREATE SEQUENCE product_models_id_seq;
+CREATE TABLE product_models (
+  id int4 NOT NULL DEFAULT nextval('product_models_id_seq'::regclass),
+  product_id int4 NOT NULL,
+  model_name varchar(100) NOT NULL,
+  price numeric(10,2) NOT NULL,
+  stock int4 NOT NULL DEFAULT 0 CHECK (stock >= 0),
+  PRIMARY KEY (id)
+);
+
+CREATE SEQUENCE product_images_id_seq;
+CREATE TABLE product_images (
+  id int4 NOT NULL DEFAULT nextval('product_images_id_seq'::regclass),
+  product_id int4 NOT NULL,
+  url text NOT NULL,
+  PRIMARY KEY (id)
+);
+
+CREATE SEQUENCE orders_id_seq;
+CREATE TABLE orders (
+  id int4 NOT NULL DEFAULT nextval('orders_id_seq'::regclass),
+  user_id int4 NOT NULL,
+  status varchar(20) NOT NULL CHECK (status IN (
+    'pending', 'toship', 'toreceive', 'toreview', 'refund', 'cancelled'
+  )),
+  total_price numeric(10,2) NOT NULL,
+  address text,
+  cancel_reason text,
+  created_at timestamp(6) DEFAULT CURRENT_TIMESTAMP,
+  updated_at timestamp(6) DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id)
+);
+
+-- 订单状态流转记录，状态取值与 back/orderstate 中的定义保持一致
+CREATE SEQUENCE order_status_history_id_seq;
+CREATE TABLE order_status_history (
+  id int4 NOT NULL DEFAULT nextval('order_status_history_id_seq'::regclass),
+  order_id int4 NOT NULL,
+  from_status varchar(20),
+  to_status varchar(20) NOT NULL,
+  actor varchar(100) NOT NULL,
+  note text,
+  created_at timestamp(6) DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id)
+);
+CREATE INDEX idx_order_status_history_order_id ON order_status_history (order_id);
+
+CREATE SEQUENCE order_items_id_seq;
+CREATE TABLE order_items (
+  id int4 NOT NULL DEFAULT nextval('order_items_id_seq'::regclass),
+  order_id int4 NOT NULL,
+  product_id int4 NOT NULL,
+  model_id int4 NOT NULL,
+  quantity int4 NOT NULL DEFAULT 1,
+  price numeric(10,2) NOT NULL,
+  PRIMARY KEY (id)
+);
+
+CREATE SEQUENCE cart_id_seq;
+CREATE TABLE cart (
+  id int4 NOT NULL DEFAULT nextval('cart_id_seq'::regclass),
+  user_id int4 NOT NULL,
+  product_id int4 NOT NULL,
+  model_id int4 NOT NULL,
+  quantity int4 NOT NULL DEFAULT 1 CHECK (quantity > 0),
+  created_at timestamp(6) DEFAULT CURRENT_TIMESTAMP,
+  updated_at timestamp(6) DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  UNIQUE (user_id, product_id, model_id)
+);
+
+CREATE SEQUENCE product_reviews_id_seq;
+CREATE TABLE product_reviews (
+  id int4 NOT NULL DEFAULT nextval('product_reviews_id_seq'::regclass),
+  product_id int4 NOT NULL,
+  model_id int4 NOT NULL,
+  username varchar(64) NOT NULL,
+  rating int4 NOT NULL CHECK (rating >= 1 AND rating <= 5),
+  content text,
+  created_at timestamp(6) DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id)
+);
+
+-- 超时订单扫描
+CREATE INDEX idx_orders_status_created_at ON orders (status, created_at);
+
+-- 添加外键约束
+ALTER TABLE product_models ADD CONSTRAINT fk_product_models_product_id FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE;
+ALTER TABLE product_images ADD CONSTRAINT fk_product_images_product_id FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE;
+ALTER TABLE orders ADD CONSTRAINT fk_orders_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE order_status_history ADD CONSTRAINT fk_order_status_history_order_id FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE;
+ALTER TABLE order_items ADD CONSTRAINT fk_order_items_order_id FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE;
+ALTER TABLE order_items ADD CONSTRAINT fk_order_items_product_id FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE;
+ALTER TABLE order_items ADD CONSTRAINT fk_order_items_model_id FOREIGN KEY (model_id) REFERENCES product_models(id) ON DELETE CASCADE;
+ALTER TABLE cart ADD CONSTRAINT fk_cart_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE cart ADD CONSTRAINT fk_cart_product_id FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE;
+ALTER TABLE cart ADD CONSTRAINT fk_cart_model_id FOREIGN KEY (model_id) REFERENCES product_models(id) ON DELETE CASCADE;
+ALTER TABLE product_reviews ADD CONSTRAINT fk_product_reviews_product_id FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE;
+ALTER TABLE product_reviews ADD CONSTRAINT fk_product_reviews_model_id FOREIGN KEY (model_id) REFERENCES product_models(id) ON DELETE CASCADE;
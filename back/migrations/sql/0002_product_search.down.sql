@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_products_description_trgm;
+DROP INDEX IF EXISTS idx_products_title_trgm;
@@ -0,0 +1,5 @@
+-- 商品搜索：pg_trgm 三元组索引，支持中文子串匹配和英文拼写容错
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX idx_products_title_trgm ON products USING gin (title gin_trgm_ops);
+CREATE INDEX idx_products_description_trgm ON products USING gin (description gin_trgm_ops);
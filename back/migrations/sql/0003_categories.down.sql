@@ -0,0 +1,5 @@
+ALTER TABLE products ADD COLUMN category varchar(100);
+UPDATE products p SET category = c.name FROM categories c WHERE c.id = p.category_id;
+ALTER TABLE products DROP COLUMN category_id;
+DROP TABLE categories;
+DROP SEQUENCE categories_id_seq;
@@ -0,0 +1,31 @@
+-- 商品分类：树形结构，products.category（自由文本）改为引用 categories.id
+CREATE SEQUENCE categories_id_seq;
+CREATE TABLE categories (
+  id int4 NOT NULL DEFAULT nextval('categories_id_seq'::regclass),
+  parent_id int4 REFERENCES categories (id) ON DELETE RESTRICT,
+  name varchar(100) NOT NULL,
+  slug varchar(100) NOT NULL UNIQUE CHECK (slug ~ '^[a-z0-9]+(-[a-z0-9]+)*$'),
+  sort_order int4 NOT NULL DEFAULT 0,
+  PRIMARY KEY (id),
+  CHECK (parent_id IS NULL OR parent_id <> id)
+);
+CREATE INDEX idx_categories_parent_id ON categories (parent_id);
+
+ALTER TABLE products ADD COLUMN category_id int4 REFERENCES categories (id) ON DELETE SET NULL;
+CREATE INDEX idx_products_category_id ON products (category_id);
+
+-- 把已有的分类文本迁移为一级分类，常见分类使用固定 slug，其余按名称生成
+INSERT INTO categories (name, slug, sort_order)
+SELECT name,
+       CASE name
+         WHEN '手机' THEN 'phones'
+         WHEN '电脑' THEN 'computers'
+         WHEN '耳机' THEN 'headphones'
+         ELSE 'category-' || substr(md5(name), 1, 8)
+       END,
+       row_number() OVER (ORDER BY name)
+FROM (SELECT DISTINCT category AS name FROM products WHERE category IS NOT NULL AND category <> '') t;
+
+UPDATE products p SET category_id = c.id FROM categories c WHERE c.name = p.category;
+
+ALTER TABLE products DROP COLUMN category;
@@ -0,0 +1,23 @@
+-- 商品统计：销量、评价数、评分合计，随支付/退款和评价写入同步更新
+CREATE TABLE product_stats (
+  product_id int4 NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+  sales_count int4 NOT NULL DEFAULT 0 CHECK (sales_count >= 0),
+  review_count int4 NOT NULL DEFAULT 0 CHECK (review_count >= 0),
+  rating_sum int4 NOT NULL DEFAULT 0 CHECK (rating_sum >= 0),
+  updated_at timestamp(6) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (product_id)
+);
+
+-- 按已有数据回填，计入销量的状态与 orderstate.SalesStatuses 一致
+INSERT INTO product_stats (product_id, sales_count, review_count, rating_sum)
+SELECT p.id, COALESCE(s.sales, 0), COALESCE(r.cnt, 0), COALESCE(r.total, 0)
+FROM products p
+LEFT JOIN (
+  SELECT oi.product_id, SUM(oi.quantity) AS sales
+  FROM order_items oi JOIN orders o ON o.id = oi.order_id
+  WHERE o.status IN ('toship', 'toreceive', 'toreview')
+  GROUP BY oi.product_id
+) s ON s.product_id = p.id
+LEFT JOIN (
+  SELECT product_id, COUNT(*) AS cnt, SUM(rating) AS total FROM product_reviews GROUP BY product_id
+) r ON r.product_id = p.id;
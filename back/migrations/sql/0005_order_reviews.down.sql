@@ -0,0 +1,8 @@
+ALTER TABLE product_reviews DROP COLUMN order_item_id;
+ALTER TABLE product_reviews DROP COLUMN user_id;
+
+UPDATE orders SET status = 'toreview' WHERE status = 'completed';
+ALTER TABLE orders DROP CONSTRAINT orders_status_check;
+ALTER TABLE orders ADD CONSTRAINT orders_status_check CHECK (status IN (
+  'pending', 'toship', 'toreceive', 'toreview', 'refund', 'cancelled'
+));
@@ -0,0 +1,8 @@
+-- 评价与订单明细关联：每个订单明细最多一条评价，全部评价后订单进入 completed
+ALTER TABLE orders DROP CONSTRAINT orders_status_check;
+ALTER TABLE orders ADD CONSTRAINT orders_status_check CHECK (status IN (
+  'pending', 'toship', 'toreceive', 'toreview', 'completed', 'refund', 'cancelled'
+));
+
+ALTER TABLE product_reviews ADD COLUMN user_id int4 REFERENCES users (id) ON DELETE SET NULL;
+ALTER TABLE product_reviews ADD COLUMN order_item_id int4 UNIQUE REFERENCES order_items (id) ON DELETE SET NULL;
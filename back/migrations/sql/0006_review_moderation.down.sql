@@ -0,0 +1,9 @@
+DROP TABLE review_votes;
+DROP TABLE review_images;
+DROP SEQUENCE review_images_id_seq;
+DROP INDEX idx_product_reviews_status_created_at;
+DROP INDEX idx_product_reviews_product_status;
+ALTER TABLE product_reviews DROP COLUMN moderated_at;
+ALTER TABLE product_reviews DROP COLUMN moderated_by;
+ALTER TABLE product_reviews DROP COLUMN helpful_count;
+ALTER TABLE product_reviews DROP COLUMN status;
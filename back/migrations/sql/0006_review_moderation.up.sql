@@ -0,0 +1,27 @@
+-- 评价审核、评价图片和“有用”投票
+ALTER TABLE product_reviews ADD COLUMN status varchar(20) NOT NULL DEFAULT 'approved'
+  CHECK (status IN ('pending', 'approved', 'hidden'));
+ALTER TABLE product_reviews ADD COLUMN helpful_count int4 NOT NULL DEFAULT 0 CHECK (helpful_count >= 0);
+ALTER TABLE product_reviews ADD COLUMN moderated_by varchar(64);
+ALTER TABLE product_reviews ADD COLUMN moderated_at timestamp(6);
+
+-- 商品评价列表（只展示已通过的）和审核队列
+CREATE INDEX idx_product_reviews_product_status ON product_reviews (product_id, status, created_at);
+CREATE INDEX idx_product_reviews_status_created_at ON product_reviews (status, created_at);
+
+CREATE SEQUENCE review_images_id_seq;
+CREATE TABLE review_images (
+  id int4 NOT NULL DEFAULT nextval('review_images_id_seq'::regclass),
+  review_id int4 NOT NULL REFERENCES product_reviews (id) ON DELETE CASCADE,
+  url varchar(255) NOT NULL,
+  sort_order int4 NOT NULL DEFAULT 0,
+  PRIMARY KEY (id)
+);
+CREATE INDEX idx_review_images_review_id ON review_images (review_id);
+
+CREATE TABLE review_votes (
+  review_id int4 NOT NULL REFERENCES product_reviews (id) ON DELETE CASCADE,
+  user_id int4 NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  created_at timestamp(6) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (review_id, user_id)
+);
@@ -0,0 +1,4 @@
+-- 只能还原为本地访问路径，存放在 S3 中的文件需另行迁回 upload.dir
+UPDATE users SET avatar = '/uploads/' || avatar WHERE avatar <> '' AND avatar NOT LIKE '/%' AND avatar NOT LIKE '%://%';
+UPDATE product_images SET url = '/uploads/' || url WHERE url <> '' AND url NOT LIKE '/%' AND url NOT LIKE '%://%';
+UPDATE review_images SET url = '/uploads/' || url WHERE url <> '' AND url NOT LIKE '/%' AND url NOT LIKE '%://%';
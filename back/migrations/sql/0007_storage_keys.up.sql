@@ -0,0 +1,5 @@
+-- 图片字段改为保存对象存储的 key，原本地上传文件的访问路径 /uploads/<文件名> 转为 key <文件名>；
+-- http(s) 开头的外部地址保持不变，展示时原样返回
+UPDATE users SET avatar = substr(avatar, length('/uploads/') + 1) WHERE avatar LIKE '/uploads/%';
+UPDATE product_images SET url = substr(url, length('/uploads/') + 1) WHERE url LIKE '/uploads/%';
+UPDATE review_images SET url = substr(url, length('/uploads/') + 1) WHERE url LIKE '/uploads/%';
@@ -0,0 +1,9 @@
+ALTER TABLE orders DROP COLUMN ship_postal_code;
+ALTER TABLE orders DROP COLUMN ship_detail;
+ALTER TABLE orders DROP COLUMN ship_district;
+ALTER TABLE orders DROP COLUMN ship_city;
+ALTER TABLE orders DROP COLUMN ship_province;
+ALTER TABLE orders DROP COLUMN ship_phone;
+ALTER TABLE orders DROP COLUMN ship_recipient;
+DROP TABLE user_addresses;
+DROP SEQUENCE user_addresses_id_seq;
@@ -0,0 +1,29 @@
+-- 用户收货地址簿，每个用户至多一个默认地址
+CREATE SEQUENCE user_addresses_id_seq;
+CREATE TABLE user_addresses (
+  id int4 NOT NULL DEFAULT nextval('user_addresses_id_seq'::regclass),
+  user_id int4 NOT NULL,
+  recipient varchar(32) NOT NULL,
+  phone varchar(20) NOT NULL,
+  province varchar(32) NOT NULL,
+  city varchar(32) NOT NULL,
+  district varchar(32) NOT NULL,
+  detail varchar(200) NOT NULL,
+  postal_code varchar(6) NOT NULL DEFAULT '',
+  is_default boolean NOT NULL DEFAULT false,
+  created_at timestamp(6) DEFAULT CURRENT_TIMESTAMP,
+  updated_at timestamp(6) DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id)
+);
+CREATE INDEX idx_user_addresses_user_id ON user_addresses (user_id);
+CREATE UNIQUE INDEX uq_user_addresses_default ON user_addresses (user_id) WHERE is_default;
+ALTER TABLE user_addresses ADD CONSTRAINT fk_user_addresses_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+-- 下单时的收货地址快照，之后修改或删除地址簿不影响订单；orders.address 保留拼接后的完整地址
+ALTER TABLE orders ADD COLUMN ship_recipient varchar(32);
+ALTER TABLE orders ADD COLUMN ship_phone varchar(20);
+ALTER TABLE orders ADD COLUMN ship_province varchar(32);
+ALTER TABLE orders ADD COLUMN ship_city varchar(32);
+ALTER TABLE orders ADD COLUMN ship_district varchar(32);
+ALTER TABLE orders ADD COLUMN ship_detail varchar(200);
+ALTER TABLE orders ADD COLUMN ship_postal_code varchar(6);
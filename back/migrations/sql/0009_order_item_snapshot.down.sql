@@ -0,0 +1,11 @@
+-- 商品已删除的订单明细无法恢复外键，回滚时一并删除
+DELETE FROM order_items WHERE product_id IS NULL OR model_id IS NULL;
+ALTER TABLE order_items DROP CONSTRAINT fk_order_items_model_id;
+ALTER TABLE order_items DROP CONSTRAINT fk_order_items_product_id;
+ALTER TABLE order_items ALTER COLUMN model_id SET NOT NULL;
+ALTER TABLE order_items ALTER COLUMN product_id SET NOT NULL;
+ALTER TABLE order_items ADD CONSTRAINT fk_order_items_product_id FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE;
+ALTER TABLE order_items ADD CONSTRAINT fk_order_items_model_id FOREIGN KEY (model_id) REFERENCES product_models(id) ON DELETE CASCADE;
+ALTER TABLE order_items DROP COLUMN image;
+ALTER TABLE order_items DROP COLUMN model_name;
+ALTER TABLE order_items DROP COLUMN title;
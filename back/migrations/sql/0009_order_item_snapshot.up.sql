@@ -0,0 +1,22 @@
+-- 订单明细保存下单时的商品标题、型号名称和主图，商品或型号删除后订单仍可完整展示
+ALTER TABLE order_items ADD COLUMN title varchar(255);
+ALTER TABLE order_items ADD COLUMN model_name varchar(100);
+ALTER TABLE order_items ADD COLUMN image text;
+
+UPDATE order_items oi SET
+  title = p.title,
+  model_name = m.model_name,
+  image = (SELECT url FROM product_images WHERE product_id = p.id ORDER BY id LIMIT 1)
+FROM products p, product_models m
+WHERE p.id = oi.product_id AND m.id = oi.model_id;
+
+ALTER TABLE order_items ALTER COLUMN title SET NOT NULL;
+ALTER TABLE order_items ALTER COLUMN model_name SET NOT NULL;
+
+-- 删除商品或型号不再级联删除订单明细，只把引用置空
+ALTER TABLE order_items DROP CONSTRAINT fk_order_items_product_id;
+ALTER TABLE order_items DROP CONSTRAINT fk_order_items_model_id;
+ALTER TABLE order_items ALTER COLUMN product_id DROP NOT NULL;
+ALTER TABLE order_items ALTER COLUMN model_id DROP NOT NULL;
+ALTER TABLE order_items ADD CONSTRAINT fk_order_items_product_id FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE SET NULL;
+ALTER TABLE order_items ADD CONSTRAINT fk_order_items_model_id FOREIGN KEY (model_id) REFERENCES product_models(id) ON DELETE SET NULL;
@@ -0,0 +1,2 @@
+DROP TABLE payments;
+DROP SEQUENCE payments_id_seq;
@@ -0,0 +1,18 @@
+-- 订单的支付记录，每次发起支付对应支付渠道的一笔交易；同一订单同时至多一笔待支付记录
+CREATE SEQUENCE payments_id_seq;
+CREATE TABLE payments (
+  id int4 NOT NULL DEFAULT nextval('payments_id_seq'::regclass),
+  order_id int4 NOT NULL,
+  provider varchar(32) NOT NULL,
+  transaction_id varchar(128) NOT NULL,
+  amount numeric(10,2) NOT NULL CHECK (amount >= 0),
+  status varchar(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'failed')),
+  pay_url text,
+  created_at timestamp(6) DEFAULT CURRENT_TIMESTAMP,
+  updated_at timestamp(6) DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id)
+);
+CREATE INDEX idx_payments_order_id ON payments (order_id);
+CREATE UNIQUE INDEX uq_payments_transaction ON payments (provider, transaction_id);
+CREATE UNIQUE INDEX uq_payments_order_pending ON payments (order_id) WHERE status = 'pending';
+ALTER TABLE payments ADD CONSTRAINT fk_payments_order_id FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE;
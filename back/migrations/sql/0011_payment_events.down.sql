@@ -0,0 +1,2 @@
+DROP TABLE payment_events;
+DROP SEQUENCE payment_events_id_seq;
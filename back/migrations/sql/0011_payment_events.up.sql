@@ -0,0 +1,15 @@
+-- 已处理的支付渠道异步通知，同一渠道的通知 ID 只处理一次
+CREATE SEQUENCE payment_events_id_seq;
+CREATE TABLE payment_events (
+  id int4 NOT NULL DEFAULT nextval('payment_events_id_seq'::regclass),
+  provider varchar(32) NOT NULL,
+  event_id varchar(128) NOT NULL,
+  payment_id int4 NOT NULL,
+  status varchar(20) NOT NULL,
+  payload text NOT NULL,
+  received_at timestamp(6) DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX uq_payment_events_event ON payment_events (provider, event_id);
+CREATE INDEX idx_payment_events_payment_id ON payment_events (payment_id);
+ALTER TABLE payment_events ADD CONSTRAINT fk_payment_events_payment_id FOREIGN KEY (payment_id) REFERENCES payments(id) ON DELETE CASCADE;
@@ -0,0 +1,14 @@
+-- 客户端通过 Idempotency-Key 请求头提交的请求及其响应，保留期内同一用户的同一 key 重复请求直接返回原响应。
+-- status_code 为空表示请求仍在处理中
+CREATE TABLE idempotency_keys (
+  user_id int4 NOT NULL,
+  idempotency_key varchar(255) NOT NULL,
+  fingerprint char(64) NOT NULL,
+  status_code int4,
+  response bytea,
+  created_at timestamp(6) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  completed_at timestamp(6),
+  PRIMARY KEY (user_id, idempotency_key)
+);
+CREATE INDEX idx_idempotency_keys_created_at ON idempotency_keys (created_at);
+ALTER TABLE idempotency_keys ADD CONSTRAINT fk_idempotency_keys_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
@@ -0,0 +1,4 @@
+DROP TABLE refund_images;
+DROP SEQUENCE refund_images_id_seq;
+DROP TABLE refunds;
+DROP SEQUENCE refunds_id_seq;
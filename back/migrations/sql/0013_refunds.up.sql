@@ -0,0 +1,39 @@
+-- 订单明细的退款/退货申请，状态独立于订单：requested → approved → completed，或 requested → rejected。
+-- 同一明细同时至多一条未被拒绝的申请
+CREATE SEQUENCE refunds_id_seq;
+CREATE TABLE refunds (
+  id int4 NOT NULL DEFAULT nextval('refunds_id_seq'::regclass),
+  order_id int4 NOT NULL,
+  order_item_id int4 NOT NULL,
+  user_id int4 NOT NULL,
+  type varchar(20) NOT NULL CHECK (type IN ('refund', 'return')),
+  reason varchar(200) NOT NULL,
+  amount numeric(10,2) NOT NULL CHECK (amount >= 0),
+  status varchar(20) NOT NULL DEFAULT 'requested' CHECK (status IN ('requested', 'approved', 'rejected', 'completed')),
+  reviewed_by varchar(64),
+  review_note varchar(200),
+  reviewed_at timestamp(6),
+  provider_refund_id varchar(128),
+  completed_at timestamp(6),
+  created_at timestamp(6) DEFAULT CURRENT_TIMESTAMP,
+  updated_at timestamp(6) DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id)
+);
+CREATE INDEX idx_refunds_order_id ON refunds (order_id);
+CREATE INDEX idx_refunds_user_id ON refunds (user_id);
+CREATE INDEX idx_refunds_status_created_at ON refunds (status, created_at);
+CREATE UNIQUE INDEX uq_refunds_order_item_active ON refunds (order_item_id) WHERE status <> 'rejected';
+ALTER TABLE refunds ADD CONSTRAINT fk_refunds_order_id FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE;
+ALTER TABLE refunds ADD CONSTRAINT fk_refunds_order_item_id FOREIGN KEY (order_item_id) REFERENCES order_items(id) ON DELETE CASCADE;
+ALTER TABLE refunds ADD CONSTRAINT fk_refunds_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+-- 申请的凭证图片，object_key 为对象存储中的 key，接口返回时换成签名地址
+CREATE SEQUENCE refund_images_id_seq;
+CREATE TABLE refund_images (
+  id int4 NOT NULL DEFAULT nextval('refund_images_id_seq'::regclass),
+  refund_id int4 NOT NULL REFERENCES refunds (id) ON DELETE CASCADE,
+  object_key varchar(255) NOT NULL,
+  sort_order int4 NOT NULL DEFAULT 0,
+  PRIMARY KEY (id)
+);
+CREATE INDEX idx_refund_images_refund_id ON refund_images (refund_id);
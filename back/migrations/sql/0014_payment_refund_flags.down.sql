@@ -0,0 +1,4 @@
+DROP INDEX idx_payments_needs_refund;
+ALTER TABLE payments DROP COLUMN provider_refund_id;
+ALTER TABLE payments DROP COLUMN refunded_at;
+ALTER TABLE payments DROP COLUMN needs_refund;
@@ -0,0 +1,11 @@
+-- 支付成功时订单已不是待付款（已取消，或已由另一笔支付付款），这笔钱需要原路退回：
+-- needs_refund 标记待退回，退回后记录 refunded_at 和渠道退款单号
+ALTER TABLE payments ADD COLUMN needs_refund boolean NOT NULL DEFAULT false;
+ALTER TABLE payments ADD COLUMN refunded_at timestamp(6);
+ALTER TABLE payments ADD COLUMN provider_refund_id varchar(128);
+CREATE INDEX idx_payments_needs_refund ON payments (id) WHERE needs_refund AND refunded_at IS NULL;
+
+-- 已有的这类支付：成功但订单已取消
+UPDATE payments p SET needs_refund = true
+FROM orders o
+WHERE o.id = p.order_id AND p.status = 'succeeded' AND o.status = 'cancelled';
@@ -0,0 +1,106 @@
+package orderstate
+
+import (
+	"fmt"
+	"time"
+)
+
+// Status 订单状态，取值与 orders.status 的 CHECK 约束保持一致
+type Status string
+
+const (
+	Pending   Status = "pending"   // 待付款
+	ToShip    Status = "toship"    // 待发货
+	ToReceive Status = "toreceive" // 待收货
+	ToReview  Status = "toreview"  // 待评价
+	Completed Status = "completed" // 已完成（全部商品已评价）
+	Refund    Status = "refund"    // 退款/售后
+	Cancelled Status = "cancelled" // 已取消
+)
+
+// 系统自动操作（如超时取消）的操作人
+const ActorSystem = "system"
+
+// UserActor 用户本人操作时记录的操作人
+func UserActor(username string) string {
+	return "user:" + username
+}
+
+// PaymentActor 支付渠道确认付款结果时记录的操作人
+func PaymentActor(provider string) string {
+	return "payment:" + provider
+}
+
+// TransitionError 非法的状态流转
+type TransitionError struct {
+	From Status
+	To   Status
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("订单状态不能从 %s 变更为 %s", e.From, e.To)
+}
+
+// StateMachine 定义订单状态及允许的流转
+type StateMachine struct {
+	statuses    []Status
+	transitions map[Status][]Status
+}
+
+// Orders 订单状态机，所有修改订单状态的地方都应通过它
+var Orders = StateMachine{
+	statuses: []Status{Pending, ToShip, ToReceive, ToReview, Completed, Refund, Cancelled},
+	transitions: map[Status][]Status{
+		Pending:   {ToShip, Cancelled},
+		ToShip:    {ToReceive, Refund},
+		ToReceive: {ToReview, Refund},
+		ToReview:  {Completed, Refund},
+	},
+}
+
+// SalesStatuses 计入商品销量的状态：已付款且未退款、未取消
+var SalesStatuses = []Status{ToShip, ToReceive, ToReview, Completed}
+
+// CountsAsSale 判断该状态的订单是否计入销量
+func CountsAsSale(s Status) bool {
+	for _, v := range SalesStatuses {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Statuses 返回全部订单状态
+func (m StateMachine) Statuses() []Status {
+	return append([]Status(nil), m.statuses...)
+}
+
+// Valid 判断是否为已定义的状态
+func (m StateMachine) Valid(s Status) bool {
+	for _, v := range m.statuses {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Check 校验 from -> to 是否允许，不允许时返回 *TransitionError
+func (m StateMachine) Check(from, to Status) error {
+	for _, next := range m.transitions[from] {
+		if next == to {
+			return nil
+		}
+	}
+	return &TransitionError{From: from, To: to}
+}
+
+// HistoryEntry 一条状态变更记录
+type HistoryEntry struct {
+	From      string
+	To        string
+	Actor     string
+	Note      string
+	CreatedAt time.Time
+}
@@ -0,0 +1,245 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// MockOutcome 模拟渠道的付款结果
+type MockOutcome string
+
+const (
+	MockSuccess MockOutcome = "success"
+	MockFailure MockOutcome = "failure"
+)
+
+// 模拟渠道发送通知的最多尝试次数
+const mockNotifyAttempts = 4
+
+// Mock 模拟支付渠道，用于本地开发和测试，交易只保存在内存中。
+// 创建的交易在 delay 内查询为 pending，之后按 outcome 变为 succeeded 或 failed；delay 为 0 时首次查询即有结果。
+// 设置了 NotifyURL 时，交易有结果后会像真实渠道一样向该地址发送签名的异步通知，失败时重试
+type Mock struct {
+	// NotifyURL 异步通知地址，如 http://localhost:8080/api/payment/webhook，为空时不发送
+	NotifyURL string
+
+	mu       sync.Mutex
+	outcome  MockOutcome
+	delay    time.Duration
+	secret   []byte
+	now      func() time.Time
+	client   *http.Client
+	payments map[string]*mockPayment
+	refunds  map[string]string // 商户退款单号 -> 渠道退款单号
+}
+
+type mockPayment struct {
+	reference string
+	amount    int64
+	refunded  int64
+	outcome   MockOutcome
+	readyAt   time.Time
+}
+
+// mockEvent 模拟渠道的通知内容
+type mockEvent struct {
+	ID            string `json:"id"`
+	Type          string `json:"type"` // payment.succeeded 或 payment.failed
+	TransactionID string `json:"transaction_id"`
+	Reference     string `json:"reference"`
+	Created       int64  `json:"created"`
+}
+
+// NewMock 创建模拟渠道，secret 用于签名和校验异步通知
+func NewMock(outcome MockOutcome, delay time.Duration, secret []byte) *Mock {
+	return &Mock{
+		outcome:  outcome,
+		delay:    delay,
+		secret:   secret,
+		now:      time.Now,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		payments: map[string]*mockPayment{},
+		refunds:  map[string]string{},
+	}
+}
+
+// SetOutcome 修改之后创建的交易的结果和延迟
+func (m *Mock) SetOutcome(outcome MockOutcome, delay time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.outcome, m.delay = outcome, delay
+}
+
+// SetClock 替换当前时间函数，用于测试中控制交易何时有结果
+func (m *Mock) SetClock(now func() time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = now
+}
+
+// clock 在锁内读取当前时间，供不持有锁的调用方使用
+func (m *Mock) clock() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.now()
+}
+
+func (m *Mock) Name() string {
+	return "mock"
+}
+
+func (m *Mock) CreateIntent(ctx context.Context, req IntentRequest) (*Intent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := "mock_" + randomID()
+	p := &mockPayment{reference: req.Reference, amount: req.Amount, outcome: m.outcome, readyAt: m.now().Add(m.delay)}
+	m.payments[id] = p
+	if m.NotifyURL != "" {
+		go m.notify(m.NotifyURL, id, m.delay)
+	}
+	return &Intent{TransactionID: id, Status: StatusPending}, nil
+}
+
+func (m *Mock) QueryPayment(ctx context.Context, transactionID string) (Status, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.payments[transactionID]
+	if !ok {
+		return "", ErrUnknownTransaction
+	}
+	return m.status(p), nil
+}
+
+func (m *Mock) Refund(ctx context.Context, transactionID string, amount int64, reference string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id, ok := m.refunds[reference]; ok {
+		return id, nil
+	}
+	p, ok := m.payments[transactionID]
+	if !ok {
+		return "", ErrUnknownTransaction
+	}
+	if m.status(p) != StatusSucceeded || amount <= 0 || p.refunded+amount > p.amount {
+		return "", ErrNotRefundable
+	}
+	p.refunded += amount
+	id := "mock_refund_" + randomID()
+	m.refunds[reference] = id
+	return id, nil
+}
+
+func (m *Mock) ParseWebhook(signature string, body []byte) (*Event, error) {
+	if err := VerifyWebhook(m.secret, body, signature, m.clock()); err != nil {
+		return nil, err
+	}
+	var e mockEvent
+	if err := json.Unmarshal(body, &e); err != nil || e.ID == "" || e.TransactionID == "" {
+		return nil, ErrInvalidEvent
+	}
+	event := &Event{ID: e.ID, TransactionID: e.TransactionID, Status: StatusPending}
+	switch e.Type {
+	case "payment.succeeded":
+		event.Status = StatusSucceeded
+	case "payment.failed":
+		event.Status = StatusFailed
+	}
+	return event, nil
+}
+
+// SignedEvent 生成交易当前结果的签名通知，返回请求体和 SignatureHeader 的值，
+// 可用于在没有 NotifyURL 时手动模拟渠道回调。交易仍为 pending 时返回错误
+func (m *Mock) SignedEvent(transactionID string) ([]byte, string, error) {
+	m.mu.Lock()
+	p, ok := m.payments[transactionID]
+	var status Status
+	if ok {
+		status = m.status(p)
+	}
+	now := m.now()
+	m.mu.Unlock()
+	if !ok {
+		return nil, "", ErrUnknownTransaction
+	}
+	if !status.Final() {
+		return nil, "", fmt.Errorf("交易 %s 尚无结果", transactionID)
+	}
+	body, err := json.Marshal(mockEvent{
+		ID:            "evt_" + randomID(),
+		Type:          "payment." + string(status),
+		TransactionID: transactionID,
+		Reference:     p.reference,
+		Created:       now.Unix(),
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return body, SignWebhook(m.secret, body, now), nil
+}
+
+// notify 等交易有结果后发送通知，非 2xx 响应按 1s、2s、4s 间隔重试，重试时通知 ID 不变
+func (m *Mock) notify(url, transactionID string, delay time.Duration) {
+	time.Sleep(delay)
+	body, _, err := m.SignedEvent(transactionID)
+	if err != nil {
+		log.Printf("模拟支付渠道生成通知失败: %v", err)
+		return
+	}
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		// 每次发送重新签名，避免重试时时间戳过期
+		err = m.post(url, body, SignWebhook(m.secret, body, m.clock()))
+		if err == nil {
+			return
+		}
+		if attempt == mockNotifyAttempts {
+			log.Printf("模拟支付渠道发送通知失败，已放弃: %v", err)
+			return
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (m *Mock) post(url string, body []byte, signature string) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, signature)
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("通知 %s 返回 %s", url, resp.Status)
+	}
+	return nil
+}
+
+// status 交易当前状态，调用方需持有锁
+func (m *Mock) status(p *mockPayment) Status {
+	if m.now().Before(p.readyAt) {
+		return StatusPending
+	}
+	if p.outcome == MockFailure {
+		return StatusFailed
+	}
+	return StatusSucceeded
+}
+
+func randomID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,72 @@
+package payment
+
+import (
+	"back/config"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownTransaction 支付渠道中不存在该交易
+var ErrUnknownTransaction = errors.New("交易不存在")
+
+// ErrNotRefundable 交易当前状态不能退款，或退款金额超过可退金额
+var ErrNotRefundable = errors.New("交易不可退款")
+
+// ErrInvalidEvent 支付通知内容无法解析
+var ErrInvalidEvent = errors.New("支付通知格式错误")
+
+// Status 支付状态，取值与 payments.status 的 CHECK 约束保持一致
+type Status string
+
+const (
+	StatusPending   Status = "pending"   // 已创建，等待用户付款或渠道确认
+	StatusSucceeded Status = "succeeded" // 付款成功
+	StatusFailed    Status = "failed"    // 付款失败或已关闭
+)
+
+// Final 是否为最终状态，最终状态不会再变化
+func (s Status) Final() bool {
+	return s == StatusSucceeded || s == StatusFailed
+}
+
+// IntentRequest 创建支付的参数，金额单位为分
+type IntentRequest struct {
+	Reference   string // 商户侧单号，渠道回调时原样带回
+	Amount      int64
+	Description string
+}
+
+// Intent 渠道创建的支付
+type Intent struct {
+	TransactionID string // 渠道交易号
+	Status        Status
+	PayURL        string // 引导用户付款的地址，模拟渠道为空
+}
+
+// Provider 支付渠道
+type Provider interface {
+	// Name 渠道名称，记录在 payments.provider
+	Name() string
+	// CreateIntent 创建支付，此时通常还未付款
+	CreateIntent(ctx context.Context, req IntentRequest) (*Intent, error)
+	// QueryPayment 查询交易当前状态，交易不存在时返回 ErrUnknownTransaction
+	QueryPayment(ctx context.Context, transactionID string) (Status, error)
+	// Refund 对已成功的交易退款 amount 分，reference 为商户侧退款单号，同一 reference 重复调用只退一次。
+	// 返回渠道退款单号
+	Refund(ctx context.Context, transactionID string, amount int64, reference string) (string, error)
+	// ParseWebhook 校验渠道异步通知的签名（请求头 SignatureHeader 的值）并解析通知内容，
+	// 签名无效时返回 ErrInvalidSignature
+	ParseWebhook(signature string, body []byte) (*Event, error)
+}
+
+// New 按配置创建支付渠道
+func New(cfg *config.Config) (Provider, error) {
+	switch cfg.Payment.Provider {
+	case config.PaymentMock:
+		m := NewMock(MockOutcome(cfg.Payment.Mock.Outcome), cfg.Payment.Mock.Delay, []byte(cfg.Payment.WebhookSecret))
+		m.NotifyURL = cfg.Payment.Mock.NotifyURL
+		return m, nil
+	}
+	return nil, fmt.Errorf("不支持的支付渠道 %q", cfg.Payment.Provider)
+}
@@ -0,0 +1,73 @@
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader 支付通知签名所在的请求头
+const SignatureHeader = "X-Payment-Signature"
+
+// 通知时间戳与当前时间允许的最大偏差，超出视为重放
+const webhookTolerance = 5 * time.Minute
+
+// ErrInvalidSignature 通知签名缺失、错误或已过期
+var ErrInvalidSignature = errors.New("支付通知签名无效")
+
+// Event 支付渠道的异步通知
+type Event struct {
+	ID            string // 通知 ID，渠道重试时保持不变，用于去重
+	TransactionID string
+	Status        Status
+}
+
+// SignWebhook 生成通知签名头：t=<unix 时间>,v1=<HMAC-SHA256(secret, t + "." + body) 的十六进制>
+func SignWebhook(secret, body []byte, t time.Time) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + webhookMAC(secret, ts, body)
+}
+
+// VerifyWebhook 校验 SignWebhook 生成的签名头，签名时间与 now 相差超过 5 分钟时视为无效
+func VerifyWebhook(secret, body []byte, header string, now time.Time) error {
+	var ts string
+	var sigs []string
+	for _, part := range strings.Split(header, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sigs = append(sigs, v)
+		}
+	}
+	t, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(sigs) == 0 {
+		return ErrInvalidSignature
+	}
+	if d := now.Sub(time.Unix(t, 0)); d > webhookTolerance || d < -webhookTolerance {
+		return ErrInvalidSignature
+	}
+	expected := webhookMAC(secret, ts, body)
+	// 更换密钥期间渠道可能同时带上新旧两个签名，任一匹配即可
+	for _, sig := range sigs {
+		if hmac.Equal([]byte(sig), []byte(expected)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func webhookMAC(secret []byte, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
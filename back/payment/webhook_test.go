@@ -0,0 +1,103 @@
+package payment
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhook(t *testing.T) {
+	secret := []byte("whsec_test")
+	body := []byte(`{"id":"evt_1","type":"payment.succeeded","transaction_id":"mock_1"}`)
+	now := time.Unix(1700000000, 0)
+	valid := SignWebhook(secret, body, now)
+	ts := "t=" + strconv.FormatInt(now.Unix(), 10)
+	sig := webhookMAC(secret, strconv.FormatInt(now.Unix(), 10), body)
+
+	tests := []struct {
+		name   string
+		body   []byte
+		header string
+		now    time.Time
+		ok     bool
+	}{
+		{"valid", body, valid, now, true},
+		{"valid within tolerance", body, valid, now.Add(webhookTolerance), true},
+		{"tampered body", []byte(`{"id":"evt_1","type":"payment.succeeded","transaction_id":"mock_2"}`), valid, now, false},
+		{"stale timestamp", body, valid, now.Add(webhookTolerance + time.Second), false},
+		{"timestamp in the future", body, valid, now.Add(-webhookTolerance - time.Second), false},
+		{"wrong secret", body, SignWebhook([]byte("other"), body, now), now, false},
+		{"rotated secret, new signature second", body, ts + ",v1=" + webhookMAC([]byte("old"), ts[2:], body) + ",v1=" + sig, now, true},
+		{"rotated secret, new signature first", body, ts + ",v1=" + sig + ",v1=deadbeef", now, true},
+		{"several signatures, none matching", body, ts + ",v1=deadbeef,v1=" + webhookMAC([]byte("old"), ts[2:], body), now, false},
+		{"spaces after commas", body, ts + ", v1=" + sig, now, true},
+		{"timestamp changed", body, "t=" + strconv.FormatInt(now.Unix()+1, 10) + ",v1=" + sig, now, false},
+		{"missing timestamp", body, "v1=" + sig, now, false},
+		{"missing signature", body, ts, now, false},
+		{"unknown scheme only", body, ts + ",v0=" + sig, now, false},
+		{"empty header", body, "", now, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyWebhook(secret, tt.body, tt.header, tt.now)
+			if tt.ok && err != nil {
+				t.Fatalf("VerifyWebhook = %v, want nil", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrInvalidSignature) {
+				t.Fatalf("VerifyWebhook = %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
+
+func TestMockParseWebhook(t *testing.T) {
+	m := NewMock(MockSuccess, 0, []byte("whsec_test"))
+	now := time.Now()
+	tests := []struct {
+		name       string
+		body       string
+		wantErr    error
+		wantStatus Status
+	}{
+		{"succeeded", `{"id":"evt_1","type":"payment.succeeded","transaction_id":"mock_1"}`, nil, StatusSucceeded},
+		{"failed", `{"id":"evt_2","type":"payment.failed","transaction_id":"mock_1"}`, nil, StatusFailed},
+		{"other type", `{"id":"evt_3","type":"payment.created","transaction_id":"mock_1"}`, nil, StatusPending},
+		{"missing id", `{"type":"payment.succeeded","transaction_id":"mock_1"}`, ErrInvalidEvent, ""},
+		{"not json", `nope`, ErrInvalidEvent, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := m.ParseWebhook(SignWebhook(m.secret, []byte(tt.body), now), []byte(tt.body))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseWebhook error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && e.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", e.Status, tt.wantStatus)
+			}
+		})
+	}
+	if _, err := m.ParseWebhook("t=1,v1=00", []byte(tests[0].body)); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("bad signature error = %v", err)
+	}
+}
+
+func TestMockParseWebhookWhileSettingClock(t *testing.T) {
+	m := NewMock(MockSuccess, 0, []byte("whsec_test"))
+	body := []byte(`{"id":"evt_1","type":"payment.succeeded","transaction_id":"mock_1"}`)
+	now := time.Now()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			m.SetClock(func() time.Time { return now })
+		}
+	}()
+	// go test -race 下检查时钟的读写都在锁内
+	for i := 0; i < 100; i++ {
+		if _, err := m.ParseWebhook(SignWebhook(m.secret, body, now), body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+}
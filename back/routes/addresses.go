@@ -0,0 +1,144 @@
+package routes
+
+import (
+	"back/service"
+	"back/store"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 收货地址簿接口：列表、新增、修改、删除、设为默认
+func RegisterAddressRoutes(r *gin.RouterGroup, addresses *service.AddressService) {
+	type AddressRequest struct {
+		Recipient  string `json:"recipient"`
+		Phone      string `json:"phone"`
+		Province   string `json:"province"`
+		City       string `json:"city"`
+		District   string `json:"district"`
+		Detail     string `json:"detail"`
+		PostalCode string `json:"postal_code"`
+		IsDefault  bool   `json:"is_default"`
+	}
+	bind := func(c *gin.Context) (service.AddressInput, bool) {
+		var req AddressRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "参数错误"})
+			return service.AddressInput{}, false
+		}
+		return service.AddressInput(req), true
+	}
+
+	r.GET("/user/addresses", func(c *gin.Context) {
+		user := currentUser(c)
+		list, err := addresses.List(c.Request.Context(), user.ID)
+		if err != nil {
+			dbError(c, err, "数据库错误")
+			return
+		}
+		items := []gin.H{}
+		for _, a := range list {
+			items = append(items, addressJSON(a))
+		}
+		c.JSON(200, items)
+	})
+
+	r.POST("/user/addresses", func(c *gin.Context) {
+		user := currentUser(c)
+		in, ok := bind(c)
+		if !ok {
+			return
+		}
+		a, err := addresses.Create(c.Request.Context(), user.ID, in)
+		if err != nil {
+			addressError(c, err)
+			return
+		}
+		c.JSON(200, addressJSON(*a))
+	})
+
+	r.PUT("/user/addresses/:id", func(c *gin.Context) {
+		user := currentUser(c)
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "参数错误"})
+			return
+		}
+		in, ok := bind(c)
+		if !ok {
+			return
+		}
+		a, err := addresses.Update(c.Request.Context(), user.ID, id, in)
+		if err != nil {
+			addressError(c, err)
+			return
+		}
+		c.JSON(200, addressJSON(*a))
+	})
+
+	r.DELETE("/user/addresses/:id", func(c *gin.Context) {
+		user := currentUser(c)
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "参数错误"})
+			return
+		}
+		if err := addresses.Delete(c.Request.Context(), user.ID, id); err != nil {
+			addressError(c, err)
+			return
+		}
+		c.JSON(200, gin.H{"success": true, "message": "删除成功"})
+	})
+
+	r.POST("/user/addresses/:id/default", func(c *gin.Context) {
+		user := currentUser(c)
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "参数错误"})
+			return
+		}
+		if err := addresses.SetDefault(c.Request.Context(), user.ID, id); err != nil {
+			addressError(c, err)
+			return
+		}
+		c.JSON(200, gin.H{"success": true, "message": "已设为默认地址"})
+	})
+}
+
+// addressError 地址接口失败的响应
+func addressError(c *gin.Context, err error) {
+	var fieldErr *service.AddressFieldError
+	switch {
+	case errors.As(err, &fieldErr):
+		c.JSON(400, gin.H{"error": fieldErr.Reason, "field": fieldErr.Field})
+	case errors.Is(err, service.ErrAddressLimit):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, store.ErrNotFound):
+		c.JSON(404, gin.H{"error": "地址不存在"})
+	default:
+		dbError(c, err, "数据库错误")
+	}
+}
+
+// addressJSON 地址的响应格式
+func addressJSON(a store.Address) gin.H {
+	h := shippingJSON(a.ShippingAddress)
+	h["id"] = a.ID
+	h["is_default"] = a.IsDefault
+	return h
+}
+
+// shippingJSON 收货地址内容的响应格式，full_address 为拼接后的完整地址
+func shippingJSON(a store.ShippingAddress) gin.H {
+	return gin.H{
+		"recipient":    a.Recipient,
+		"phone":        a.Phone,
+		"province":     a.Province,
+		"city":         a.City,
+		"district":     a.District,
+		"detail":       a.Detail,
+		"postal_code":  a.PostalCode,
+		"full_address": a.String(),
+	}
+}
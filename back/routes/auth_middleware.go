@@ -0,0 +1,66 @@
+package routes
+
+import (
+	"back/service"
+	"back/store"
+	"errors"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// gin.Context 中保存当前登录用户的 key
+const currentUserKey = "currentUser"
+
+// sessionUser 当前登录用户
+type sessionUser struct {
+	ID       int
+	Username string
+	Role     string
+}
+
+// requireUser 登录校验中间件：从 session 解析当前用户并放入 gin.Context，
+// 未登录或用户已不存在时统一返回 401。
+func requireUser(users *service.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session := sessions.Default(c)
+		username, ok := session.Get("user").(string)
+		if !ok || username == "" {
+			c.AbortWithStatusJSON(401, gin.H{"error": "未登录"})
+			return
+		}
+		u, err := users.ByUsername(c.Request.Context(), username)
+		if errors.Is(err, store.ErrNotFound) {
+			// 用户已被删除，清掉失效的 session
+			session.Delete("user")
+			session.Save()
+			c.AbortWithStatusJSON(401, gin.H{"error": "未登录"})
+			return
+		}
+		if err != nil {
+			dbError(c, err, "数据库错误")
+			c.Abort()
+			return
+		}
+		user := &sessionUser{ID: u.ID, Username: u.Username, Role: u.Role}
+		c.Set(currentUserKey, user)
+		c.Next()
+	}
+}
+
+// currentUser 返回 requireUser 放入的当前用户，只能在挂了 requireUser 的路由中调用
+func currentUser(c *gin.Context) *sessionUser {
+	return c.MustGet(currentUserKey).(*sessionUser)
+}
+
+// requireStaff 员工权限校验，需挂在 requireUser 之后，非 staff/admin 返回 403
+func requireStaff() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := currentUser(c)
+		if user.Role != store.RoleStaff && user.Role != store.RoleAdmin {
+			c.AbortWithStatusJSON(403, gin.H{"error": "无权限"})
+			return
+		}
+		c.Next()
+	}
+}
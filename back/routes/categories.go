@@ -0,0 +1,44 @@
+package routes
+
+import (
+	"back/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 分类树接口，每个节点附带含子分类在内的商品数
+func RegisterCategoriesRoute(r *gin.RouterGroup, categories *service.CategoryService) {
+	r.GET("/categories", func(c *gin.Context) {
+		tree, err := categories.Tree(c.Request.Context())
+		if err != nil {
+			dbError(c, err, "数据库错误")
+			return
+		}
+		c.JSON(200, gin.H{"categories": categoryNodesJSON(tree)})
+	})
+}
+
+func categoryNodesJSON(nodes []*service.CategoryNode) []gin.H {
+	list := []gin.H{}
+	for _, n := range nodes {
+		list = append(list, gin.H{
+			"id": n.ID, "name": n.Name, "slug": n.Slug, "sort_order": n.SortOrder,
+			"product_count": n.ProductCount, "children": categoryNodesJSON(n.Children),
+		})
+	}
+	return list
+}
+
+// categoryFilter 解析分类筛选参数：category_id 为分类 id，category 为分类 slug 或名称
+func categoryFilter(c *gin.Context) (service.CategoryFilter, bool) {
+	f := service.CategoryFilter{Slug: c.Query("category")}
+	if v := c.Query("category_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			return f, false
+		}
+		f.ID = id
+	}
+	return f, true
+}
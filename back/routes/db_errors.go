@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgconn"
+)
+
+// isDBTimeout 判断数据库错误是否由请求超时引起
+func isDBTimeout(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || (pgconn.Timeout(err) && !errors.Is(err, context.Canceled))
+}
+
+// dbError 统一返回数据库错误：超时返回 504，请求被取消（客户端已断开）返回 503，其余返回 500 和 msg
+func dbError(c *gin.Context, err error, msg string) {
+	switch {
+	case isDBTimeout(err):
+		c.JSON(504, gin.H{"error": "数据库请求超时，请稍后重试", "code": "db_timeout"})
+	case errors.Is(err, context.Canceled):
+		c.JSON(503, gin.H{"error": "请求已取消", "code": "request_canceled"})
+	default:
+		c.JSON(500, gin.H{"error": msg})
+	}
+}
@@ -0,0 +1,133 @@
+package routes
+
+import (
+	"back/service"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 客户端提交幂等键的请求头，重放的响应带 Idempotent-Replayed: true
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+)
+
+// 幂等键最大长度，与 idempotency_keys.idempotency_key 一致
+const maxIdempotencyKeyLength = 255
+
+// 带幂等键的请求体上限，用于计算请求摘要
+const maxIdempotentBody = 1 << 20
+
+// 保存响应的超时：请求 context 此时可能已超时或被取消，保存使用独立的时限
+const idempotencySaveTimeout = 5 * time.Second
+
+// idempotent 幂等键中间件，需挂在 requireUser 之后。请求没有 Idempotency-Key 时不做处理；
+// 有时保留期内同一用户用同一 key 重复提交相同请求直接返回第一次的响应，内容不同返回 422，
+// 第一次请求仍在处理中返回 409，超过 lease 仍未完成（如进程崩溃）时允许重新提交。
+// 5xx 响应不保存，客户端可以用同一 key 重试
+func idempotent(keys *service.IdempotencyService, retention, lease time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if !validIdempotencyKey(key) {
+			c.AbortWithStatusJSON(400, gin.H{"error": "Idempotency-Key 格式错误"})
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIdempotentBody))
+		if err != nil {
+			c.AbortWithStatusJSON(400, gin.H{"error": "请求体过大或读取失败"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		user := currentUser(c)
+		record, err := keys.Begin(c.Request.Context(), user.ID, key, requestFingerprint(c, body), retention, lease)
+		switch {
+		case errors.Is(err, service.ErrIdempotencyMismatch):
+			c.AbortWithStatusJSON(422, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, service.ErrIdempotencyInProgress):
+			c.AbortWithStatusJSON(409, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			dbError(c, err, "数据库错误")
+			c.Abort()
+			return
+		case record != nil:
+			c.Header(idempotencyReplayedHeader, "true")
+			c.Data(record.StatusCode, "application/json; charset=utf-8", record.Response)
+			c.Abort()
+			return
+		}
+
+		w := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), idempotencySaveTimeout)
+		defer cancel()
+		defer func() {
+			// handler panic 时释放 key，再交给外层的 Recovery 处理
+			if p := recover(); p != nil {
+				keys.Release(ctx, user.ID, key)
+				panic(p)
+			}
+		}()
+		c.Next()
+		if w.Status() >= 500 {
+			err = keys.Release(ctx, user.ID, key)
+		} else {
+			err = keys.Complete(ctx, user.ID, key, w.Status(), w.body.Bytes())
+		}
+		if err != nil {
+			log.Printf("保存幂等键 %q 的结果失败: %v", key, err)
+		}
+	}
+}
+
+// validIdempotencyKey 幂等键只能由可见 ASCII 字符组成
+func validIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x21 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// requestFingerprint 请求方法、路由和请求体的摘要，同一幂等键只能用于摘要相同的请求
+func requestFingerprint(c *gin.Context, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, c.Request.Method+" "+c.FullPath()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recordingWriter 在写出响应的同时保留一份响应体
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
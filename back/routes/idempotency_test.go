@@ -0,0 +1,106 @@
+package routes_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+const orderBody = `{"items":[{"product_id":1,"model_id":10,"quantity":1}]}`
+
+// fingerprint 与中间件计算请求摘要的方式一致，用于模拟另一个仍在处理中的请求
+func fingerprint(method, route, body string) string {
+	h := sha256.Sum256([]byte(method + " " + route + "\n" + body))
+	return hex.EncodeToString(h[:])
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	s := newTestServer(t)
+	s.login("alice")
+
+	first := s.do("POST", "/api/order/create", orderBody, "Idempotency-Key", "k1")
+	s.expect(first, 200)
+	if first.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatal("first response marked as replayed")
+	}
+
+	// 相同请求重放第一次的响应，不再下单
+	again := s.do("POST", "/api/order/create", orderBody, "Idempotency-Key", "k1")
+	s.expect(again, 200)
+	if again.Header().Get("Idempotent-Replayed") != "true" || again.Body.String() != first.Body.String() {
+		t.Fatalf("replay = %s %v, want %s", again.Body.String(), again.Header(), first.Body.String())
+	}
+	if got := s.db.ModelStock(10); got != 4 {
+		t.Fatalf("stock = %d, want 4", got)
+	}
+
+	// 同一 key 用于内容不同的请求
+	s.expect(s.do("POST", "/api/order/create", `{"items":[{"product_id":1,"model_id":10,"quantity":2}]}`, "Idempotency-Key", "k1"), 422)
+	s.expect(s.do("POST", "/api/order/pay", `{"order_id":1}`, "Idempotency-Key", "k1"), 422)
+
+	// 格式错误的 key
+	s.expect(s.do("POST", "/api/order/create", orderBody, "Idempotency-Key", "含空格 的key"), 400)
+
+	// 4xx 响应同样保存并重放
+	shortage := `{"items":[{"product_id":1,"model_id":10,"quantity":9}]}`
+	s.expect(s.do("POST", "/api/order/create", shortage, "Idempotency-Key", "k2"), 409)
+	if w := s.do("POST", "/api/order/create", shortage, "Idempotency-Key", "k2"); w.Code != 409 || w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("replayed shortage = %d %v", w.Code, w.Header())
+	}
+
+	// 不同用户的同一 key 互不影响
+	s.cookies = nil
+	s.login("bob")
+	s.expect(s.do("POST", "/api/order/create", orderBody, "Idempotency-Key", "k1"), 200)
+	if got := s.db.ModelStock(10); got != 3 {
+		t.Fatalf("stock = %d, want 3", got)
+	}
+}
+
+func TestIdempotencyKeyInProgress(t *testing.T) {
+	s := newTestServer(t)
+	s.login("alice")
+	ctx := context.Background()
+	fp := fingerprint("POST", "/api/order/create", orderBody)
+	lease := time.Minute // 与 config.Default 一致
+
+	// 另一个请求刚占用 key 且尚未完成
+	if r, err := s.svc.Idempotency.Begin(ctx, 1, "busy", fp, 24*time.Hour, lease); err != nil || r != nil {
+		t.Fatalf("Begin = %v, %v", r, err)
+	}
+	s.expect(s.do("POST", "/api/order/create", orderBody, "Idempotency-Key", "busy"), 409)
+	if got := s.db.ModelStock(10); got != 5 {
+		t.Fatalf("stock = %d, want 5", got)
+	}
+
+	// 占用超过 lease 仍未完成（进程崩溃），重新提交时回收 key 并正常处理
+	s.db.SetClock(func() time.Time { return time.Now().Add(-2 * lease) })
+	_, err := s.svc.Idempotency.Begin(ctx, 1, "crashed", fp, 24*time.Hour, lease)
+	s.db.SetClock(time.Now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := s.do("POST", "/api/order/create", orderBody, "Idempotency-Key", "crashed")
+	s.expect(w, 200)
+	if w.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatal("reclaimed key replayed a response")
+	}
+	// 回收后保存了新的响应，之后正常重放
+	again := s.do("POST", "/api/order/create", orderBody, "Idempotency-Key", "crashed")
+	if again.Code != 200 || again.Header().Get("Idempotent-Replayed") != "true" || again.Body.String() != w.Body.String() {
+		t.Fatalf("replay after reclaim = %d %s", again.Code, again.Body.String())
+	}
+	if got := s.db.ModelStock(10); got != 4 {
+		t.Fatalf("stock = %d, want 4", got)
+	}
+
+	// 已完成的记录不会因超过 lease 被回收
+	s.db.SetClock(func() time.Time { return time.Now().Add(-2 * lease) })
+	s.expect(s.do("POST", "/api/order/create", orderBody, "Idempotency-Key", "done"), 200)
+	s.db.SetClock(time.Now)
+	if w := s.do("POST", "/api/order/create", orderBody, "Idempotency-Key", "done"); w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("completed key reclaimed: %d %s", w.Code, w.Body.String())
+	}
+}
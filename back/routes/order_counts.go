@@ -1,234 +1,241 @@
 package routes
 
 import (
-	"context"
+	"back/orderstate"
+	"back/service"
+	"back/storage"
+	"back/store"
+	"errors"
 	"strconv"
-	"time"
 
-	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
-	"github.com/jackc/pgx/v4"
-	"github.com/jackc/pgx/v4/pgxpool"
 )
 
 // 订单状态统计接口
-func RegisterOrderCountsRoute(r *gin.Engine, pool *pgxpool.Pool) {
-	r.GET("/api/order/counts", func(c *gin.Context) {
-		session := sessions.Default(c)
-		username, ok := session.Get("user").(string)
-		if !ok || username == "" {
-			c.JSON(401, gin.H{"error": "未登录"})
-			return
-		}
-		// 查询用户id
-		var userID int
-		err := pool.QueryRow(context.Background(), "SELECT id FROM users WHERE username=$1", username).Scan(&userID)
+func RegisterOrderCountsRoute(r *gin.RouterGroup, orders *service.OrderService) {
+	r.GET("/order/counts", func(c *gin.Context) {
+		user := currentUser(c)
+		counts, err := orders.Counts(c.Request.Context(), user.ID)
 		if err != nil {
-			c.JSON(500, gin.H{"error": "用户不存在"})
+			dbError(c, err, "数据库错误")
 			return
 		}
-		// 查询订单状态数量
-		rows, err := pool.Query(context.Background(), "SELECT status, COUNT(*) FROM orders WHERE user_id=$1 GROUP BY status", userID)
-		if err != nil {
-			c.JSON(500, gin.H{"error": "数据库错误"})
-			return
+		resp := map[string]int{}
+		for status, n := range counts {
+			resp[string(status)] = n
 		}
-		defer rows.Close()
-		counts := map[string]int{"pending": 0, "toship": 0, "toreceive": 0, "toreview": 0, "refund": 0}
-		for rows.Next() {
-			var status string
-			var count int
-			if err := rows.Scan(&status, &count); err == nil {
-				counts[status] = count
-			}
-		}
-		c.JSON(200, counts)
+		c.JSON(200, resp)
 	})
 }
 
 // 订单列表接口
-func RegisterOrderListRoute(r *gin.Engine, pool *pgxpool.Pool) {
-	r.GET("/api/order/list", func(c *gin.Context) {
-		session := sessions.Default(c)
-		username, ok := session.Get("user").(string)
-		if !ok || username == "" {
-			c.JSON(401, gin.H{"error": "未登录"})
-			return
-		}
-		var userID int
-		err := pool.QueryRow(context.Background(), "SELECT id FROM users WHERE username=$1", username).Scan(&userID)
-		if err != nil {
-			c.JSON(500, gin.H{"error": "用户不存在"})
+func RegisterOrderListRoute(r *gin.RouterGroup, orders *service.OrderService) {
+	r.GET("/order/list", func(c *gin.Context) {
+		user := currentUser(c)
+		list, err := orders.List(c.Request.Context(), user.ID, c.Query("status"))
+		if errors.Is(err, service.ErrInvalidInput) {
+			c.JSON(400, gin.H{"error": "订单状态错误"})
 			return
 		}
-		status := c.Query("status")
-		var rows pgx.Rows
-		if status != "" {
-			rows, err = pool.Query(context.Background(),
-				`SELECT o.id, o.status, o.total_price, o.address, o.created_at, o.updated_at, COUNT(oi.id) as item_count
-				 FROM orders o LEFT JOIN order_items oi ON o.id = oi.order_id
-				 WHERE o.user_id=$1 AND o.status=$2 GROUP BY o.id ORDER BY o.created_at DESC`,
-				userID, status)
-		} else {
-			rows, err = pool.Query(context.Background(),
-				`SELECT o.id, o.status, o.total_price, o.address, o.created_at, o.updated_at, COUNT(oi.id) as item_count
-				 FROM orders o LEFT JOIN order_items oi ON o.id = oi.order_id
-				 WHERE o.user_id=$1 GROUP BY o.id ORDER BY o.created_at DESC`,
-				userID)
-		}
 		if err != nil {
-			c.JSON(500, gin.H{"error": "数据库错误"})
-			return
-		}
-		defer rows.Close()
-		var orders []gin.H
-		for rows.Next() {
-			var id int
-			var status, address string
-			var totalPrice float64
-			var createdAt, updatedAt time.Time
-			var itemCount int
-			err := rows.Scan(&id, &status, &totalPrice, &address, &createdAt, &updatedAt, &itemCount)
-			if err != nil {
-				continue
-			}
-			orders = append(orders, gin.H{
-				"id":          id,
-				"status":      status,
-				"total_price": totalPrice,
-				"address":     address,
-				"created_at":  createdAt.Format("2006-01-02 15:04:05"),
-				"updated_at":  updatedAt.Format("2006-01-02 15:04:05"),
-				"item_count":  itemCount,
+			dbError(c, err, "数据库错误")
+			return
+		}
+		var resp []gin.H
+		for _, o := range list {
+			resp = append(resp, gin.H{
+				"id":          o.ID,
+				"status":      o.Status,
+				"total_price": o.TotalPrice,
+				"address":     o.Address,
+				"created_at":  o.CreatedAt.Format("2006-01-02 15:04:05"),
+				"updated_at":  o.UpdatedAt.Format("2006-01-02 15:04:05"),
+				"item_count":  o.ItemCount,
 			})
 		}
-		c.JSON(200, orders)
+		c.JSON(200, resp)
 	})
 }
 
 // 订单创建接口
-func RegisterOrderCreateRoute(r *gin.Engine, pool *pgxpool.Pool) {
+func RegisterOrderCreateRoute(r *gin.RouterGroup, orders *service.OrderService) {
 	type CreateOrderRequest struct {
 		Items []struct {
-			ProductID int     `json:"product_id"`
-			ModelID   int     `json:"model_id"`
-			Quantity  int     `json:"quantity"`
-			Price     float64 `json:"price"`
+			ProductID int      `json:"product_id"`
+			ModelID   int      `json:"model_id"`
+			Quantity  int      `json:"quantity"`
+			Price     *float64 `json:"price"`
 		} `json:"items"`
-		Address string  `json:"address"`
-		Total   float64 `json:"total"`
+		AddressID int      `json:"address_id"` // 不传时使用默认地址
+		Total     *float64 `json:"total"`
 	}
-	r.POST("/api/order/create", func(c *gin.Context) {
-		session := sessions.Default(c)
-		username, ok := session.Get("user").(string)
-		if !ok || username == "" {
-			c.JSON(401, gin.H{"error": "未登录"})
-			return
-		}
-		var userID int
-		err := pool.QueryRow(context.Background(), "SELECT id FROM users WHERE username=$1", username).Scan(&userID)
-		if err != nil {
-			c.JSON(500, gin.H{"error": "用户不存在"})
-			return
-		}
+	r.POST("/order/create", func(c *gin.Context) {
+		user := currentUser(c)
 		var req CreateOrderRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(400, gin.H{"error": "参数错误"})
 			return
 		}
-		tx, err := pool.Begin(context.Background())
-		if err != nil {
-			c.JSON(500, gin.H{"error": "数据库错误"})
-			return
-		}
-		defer tx.Rollback(context.Background())
-		var orderID int
-		err = tx.QueryRow(context.Background(),
-			`INSERT INTO orders (user_id, status, total_price, address, created_at, updated_at)
-			 VALUES ($1, 'pending', $2, $3, NOW(), NOW()) RETURNING id`,
-			userID, req.Total, req.Address).Scan(&orderID)
-		if err != nil {
-			c.JSON(500, gin.H{"error": "下单失败"})
-			return
-		}
+		in := service.CreateOrderInput{AddressID: req.AddressID, Total: req.Total}
 		for _, item := range req.Items {
-			_, err := tx.Exec(context.Background(),
-				`INSERT INTO order_items (order_id, product_id, model_id, quantity, price)
-				 VALUES ($1, $2, $3, $4, $5)`,
-				orderID, item.ProductID, item.ModelID, item.Quantity, item.Price)
-			if err != nil {
-				c.JSON(500, gin.H{"error": "下单失败"})
-				return
-			}
+			in.Items = append(in.Items, service.OrderItemInput{
+				ProductID: item.ProductID,
+				ModelID:   item.ModelID,
+				Quantity:  item.Quantity,
+				Price:     item.Price,
+			})
 		}
-		if err := tx.Commit(context.Background()); err != nil {
-			c.JSON(500, gin.H{"error": "数据库提交失败"})
+		// 单价和总价以服务端为准，客户端传来的金额只用于核对
+		orderID, total, err := orders.Create(c.Request.Context(), user.ID, orderstate.UserActor(user.Username), in)
+		if err != nil {
+			orderCreateError(c, err)
 			return
 		}
-		c.JSON(200, gin.H{"success": true, "order_id": orderID})
+		c.JSON(200, gin.H{"success": true, "order_id": orderID, "total": total})
 	})
 }
 
-// 订单详情接口
-func RegisterOrderDetailRoute(r *gin.Engine, pool *pgxpool.Pool) {
-	r.GET("/api/order/detail", func(c *gin.Context) {
-		session := sessions.Default(c)
-		username, ok := session.Get("user").(string)
-		if !ok || username == "" {
-			c.JSON(401, gin.H{"error": "未登录"})
-			return
+// orderCreateError 下单失败的响应
+func orderCreateError(c *gin.Context, err error) {
+	var priceErr *service.PriceMismatchError
+	var totalErr *service.TotalMismatchError
+	var stockErr *service.StockShortageError
+	switch {
+	case errors.Is(err, service.ErrInvalidInput):
+		c.JSON(400, gin.H{"error": "参数错误"})
+	case errors.Is(err, service.ErrModelNotFound):
+		c.JSON(400, gin.H{"error": "商品型号不存在"})
+	case errors.Is(err, service.ErrNoAddress), errors.Is(err, service.ErrAddressNotFound):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.As(err, &priceErr):
+		items := []gin.H{}
+		for _, m := range priceErr.Items {
+			items = append(items, gin.H{
+				"product_id":   m.ProductID,
+				"model_id":     m.ModelID,
+				"client_price": m.ClientPrice,
+				"price":        m.Price,
+			})
 		}
-		var userID int
-		err := pool.QueryRow(context.Background(), "SELECT id FROM users WHERE username=$1", username).Scan(&userID)
-		if err != nil {
-			c.JSON(500, gin.H{"error": "用户不存在"})
-			return
+		c.JSON(409, gin.H{"error": "商品价格已变动，请刷新后重试", "items": items})
+	case errors.As(err, &totalErr):
+		c.JSON(409, gin.H{"error": "订单金额不一致，请刷新后重试", "total": totalErr.Total})
+	case errors.As(err, &stockErr):
+		items := []gin.H{}
+		for _, s := range stockErr.Items {
+			items = append(items, gin.H{
+				"product_id": s.ProductID,
+				"model_id":   s.ModelID,
+				"quantity":   s.Quantity,
+				"stock":      s.Stock,
+				"error":      "库存不足",
+			})
 		}
-		orderIDStr := c.Query("id")
-		orderID, err := strconv.Atoi(orderIDStr)
+		c.JSON(409, gin.H{"error": "库存不足", "items": items})
+	default:
+		dbError(c, err, "下单失败")
+	}
+}
+
+// 订单详情接口
+func RegisterOrderDetailRoute(r *gin.RouterGroup, orders *service.OrderService, files storage.Storage) {
+	r.GET("/order/detail", func(c *gin.Context) {
+		user := currentUser(c)
+		orderID, err := strconv.Atoi(c.Query("id"))
 		if err != nil {
 			c.JSON(400, gin.H{"error": "订单ID格式错误"})
 			return
 		}
-		var id int
-		var status, address string
-		var totalPrice float64
-		var createdAt, updatedAt time.Time
-		err = pool.QueryRow(context.Background(),
-			`SELECT id, status, total_price, address, created_at, updated_at FROM orders WHERE id=$1 AND user_id=$2`,
-			orderID, userID).Scan(&id, &status, &totalPrice, &address, &createdAt, &updatedAt)
-		if err != nil {
+		o, err := orders.Detail(c.Request.Context(), user.ID, orderID)
+		if errors.Is(err, store.ErrNotFound) {
 			c.JSON(404, gin.H{"error": "订单不存在"})
 			return
 		}
-		rows, err := pool.Query(context.Background(),
-			`SELECT product_id, model_id, quantity, price FROM order_items WHERE order_id=$1`, orderID)
 		if err != nil {
-			c.JSON(500, gin.H{"error": "查询明细失败"})
+			dbError(c, err, "数据库错误")
 			return
 		}
-		defer rows.Close()
 		var items []gin.H
-		for rows.Next() {
-			var productID, modelID, quantity int
-			var price float64
-			if err := rows.Scan(&productID, &modelID, &quantity, &price); err == nil {
-				items = append(items, gin.H{
-					"product_id": productID,
-					"model_id":   modelID,
-					"quantity":   quantity,
-					"price":      price,
-				})
-			}
+		for _, it := range o.Items {
+			items = append(items, gin.H{
+				"id":            it.ID,
+				"product_id":    it.ProductID,
+				"model_id":      it.ModelID,
+				"title":         it.Title,
+				"model_name":    it.ModelName,
+				"img":           storage.Resolve(files, it.Image),
+				"quantity":      it.Quantity,
+				"price":         it.Price,
+				"reviewed":      it.Reviewed,
+				"refund_status": it.RefundStatus,
+			})
+		}
+		history := []gin.H{}
+		for _, e := range o.History {
+			history = append(history, gin.H{
+				"from":       e.From,
+				"to":         e.To,
+				"actor":      e.Actor,
+				"note":       e.Note,
+				"created_at": e.CreatedAt.Format("2006-01-02 15:04:05"),
+			})
 		}
 		c.JSON(200, gin.H{
-			"id":          id,
-			"status":      status,
-			"total_price": totalPrice,
-			"address":     address,
-			"created_at":  createdAt.Format("2006-01-02 15:04:05"),
-			"updated_at":  updatedAt.Format("2006-01-02 15:04:05"),
-			"items":       items,
+			"id":            o.ID,
+			"status":        o.Status,
+			"total_price":   o.TotalPrice,
+			"address":       o.Address,
+			"shipping":      shippingJSON(o.Shipping),
+			"created_at":    o.CreatedAt.Format("2006-01-02 15:04:05"),
+			"updated_at":    o.UpdatedAt.Format("2006-01-02 15:04:05"),
+			"cancel_reason": o.CancelReason,
+			"items":         items,
+			"history":       history,
 		})
 	})
 }
+
+// 订单评价接口：待评价订单中的每个明细评价一次，全部评价后订单变为已完成
+func RegisterOrderReviewRoute(r *gin.RouterGroup, orders *service.OrderService) {
+	type ReviewRequest struct {
+		OrderID     int      `json:"order_id"`
+		OrderItemID int      `json:"order_item_id"`
+		Rating      int      `json:"rating"`
+		Content     string   `json:"content"`
+		Images      []string `json:"images"` // 通过 /reviews/images 上传得到的 key
+	}
+	r.POST("/order/review", func(c *gin.Context) {
+		user := currentUser(c)
+		var req ReviewRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "参数错误"})
+			return
+		}
+		for _, key := range req.Images {
+			if !ownsReviewImage(user.ID, key) {
+				c.JSON(400, gin.H{"error": "图片无效，请重新上传"})
+				return
+			}
+		}
+		completed, err := orders.Review(c.Request.Context(), user.ID, user.Username, req.OrderID, service.ReviewInput{
+			OrderItemID: req.OrderItemID,
+			Rating:      req.Rating,
+			Content:     req.Content,
+			Images:      req.Images,
+		}, orderstate.UserActor(user.Username))
+		switch {
+		case errors.Is(err, service.ErrInvalidInput):
+			c.JSON(400, gin.H{"error": "评分须为 1-5，内容不超过 500 字，图片不超过 6 张"})
+		case errors.Is(err, store.ErrNotFound):
+			c.JSON(404, gin.H{"error": "订单或商品不存在"})
+		case errors.Is(err, service.ErrNotReviewable):
+			c.JSON(409, gin.H{"error": "订单当前状态不可评价"})
+		case errors.Is(err, service.ErrAlreadyReviewed):
+			c.JSON(409, gin.H{"error": "该商品已评价"})
+		case err != nil:
+			dbError(c, err, "评价失败")
+		default:
+			c.JSON(200, gin.H{"success": true, "message": "评价成功", "order_completed": completed})
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package routes
+
+import (
+	"back/payment"
+	"back/service"
+	"back/store"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 订单支付接口：发起支付，已付款时 status 为 succeeded；
+// 为 pending 时客户端引导用户前往 pay_url 付款，再轮询支付状态接口
+func RegisterOrderPayRoute(r *gin.RouterGroup, payments *service.PaymentService) {
+	r.POST("/order/pay", func(c *gin.Context) {
+		var req struct {
+			OrderID int `json:"order_id"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || req.OrderID == 0 {
+			c.JSON(400, gin.H{"error": "参数错误"})
+			return
+		}
+		user := currentUser(c)
+		p, err := payments.Pay(c.Request.Context(), user.ID, req.OrderID)
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			c.JSON(404, gin.H{"error": "订单不存在"})
+		case errors.Is(err, service.ErrNotPayable):
+			c.JSON(400, gin.H{"error": "订单状态不可支付"})
+		case err != nil:
+			dbError(c, err, "支付失败")
+		default:
+			c.JSON(200, paymentJSON(p))
+		}
+	})
+}
+
+// 支付状态接口：返回订单最近一次支付，待支付时会先向支付渠道查询最新结果
+func RegisterOrderPaymentRoute(r *gin.RouterGroup, payments *service.PaymentService) {
+	r.GET("/order/payment", func(c *gin.Context) {
+		orderID, err := strconv.Atoi(c.Query("order_id"))
+		if err != nil || orderID <= 0 {
+			c.JSON(400, gin.H{"error": "参数错误"})
+			return
+		}
+		user := currentUser(c)
+		p, err := payments.Status(c.Request.Context(), user.ID, orderID)
+		if errors.Is(err, store.ErrNotFound) {
+			c.JSON(404, gin.H{"error": "支付记录不存在"})
+			return
+		}
+		if err != nil {
+			dbError(c, err, "查询支付状态失败")
+			return
+		}
+		c.JSON(200, paymentJSON(p))
+	})
+}
+
+// 支付通知上限，正常通知只有几百字节
+const maxWebhookBody = 64 << 10
+
+// 支付渠道异步通知接口，无需登录，以签名校验来源。
+// 处理成功（包括重复通知）返回 2xx，其他情况渠道会重试
+func RegisterPaymentWebhookRoute(r *gin.RouterGroup, payments *service.PaymentService) {
+	r.POST("/payment/webhook", func(c *gin.Context) {
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBody))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "请求体过大或读取失败"})
+			return
+		}
+		duplicate, err := payments.HandleWebhook(c.Request.Context(), c.GetHeader(payment.SignatureHeader), body)
+		switch {
+		case errors.Is(err, payment.ErrInvalidSignature):
+			c.JSON(401, gin.H{"error": "签名无效"})
+		case errors.Is(err, payment.ErrInvalidEvent):
+			c.JSON(400, gin.H{"error": "通知格式错误"})
+		case errors.Is(err, store.ErrNotFound):
+			// 通知可能早于支付记录写入，返回 404 让渠道稍后重试
+			c.JSON(404, gin.H{"error": "交易不存在"})
+		case err != nil:
+			dbError(c, err, "处理支付通知失败")
+		default:
+			c.JSON(200, gin.H{"received": true, "duplicate": duplicate})
+		}
+	})
+}
+
+func paymentJSON(p *store.Payment) gin.H {
+	return gin.H{
+		"payment_id":     p.ID,
+		"order_id":       p.OrderID,
+		"provider":       p.Provider,
+		"transaction_id": p.TransactionID,
+		"amount":         p.Amount,
+		"status":         p.Status,
+		"paid":           p.Status == payment.StatusSucceeded && !p.NeedsRefund,
+		"pay_url":        p.PayURL,
+		"needs_refund":   p.NeedsRefund,
+		"refunded":       p.Refunded,
+		"created_at":     p.CreatedAt.Format("2006-01-02 15:04:05"),
+		"updated_at":     p.UpdatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
@@ -0,0 +1,191 @@
+package routes_test
+
+import (
+	"back/orderstate"
+	"back/payment"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// payPending 下单一件并发起一笔一直待支付的交易，返回订单 ID 和交易号
+func (s *testServer) payPending() (int, string) {
+	s.t.Helper()
+	body := s.expect(s.do("POST", "/api/order/create", `{"items":[{"product_id":1,"model_id":10,"quantity":1}]}`), 200)
+	orderID := int(body["order_id"].(float64))
+	s.mock.SetOutcome(payment.MockSuccess, time.Hour)
+	p := s.expect(s.do("POST", "/api/order/pay", fmt.Sprintf(`{"order_id":%d}`, orderID)), 200)
+	if p["status"] != "pending" {
+		s.t.Fatalf("pay response = %v", p)
+	}
+	return orderID, p["transaction_id"].(string)
+}
+
+// webhook 发送在 at 时刻签名的支付通知，at 需与模拟渠道的时钟一致
+func (s *testServer) webhook(at time.Time, eventID, typ, transactionID string) map[string]interface{} {
+	s.t.Helper()
+	body := fmt.Sprintf(`{"id":%q,"type":%q,"transaction_id":%q}`, eventID, typ, transactionID)
+	sig := payment.SignWebhook([]byte(testWebhookSecret), []byte(body), at)
+	return s.expect(s.do("POST", "/api/payment/webhook", body, payment.SignatureHeader, sig), 200)
+}
+
+func (s *testServer) orderStatus(orderID int) orderstate.Status {
+	s.t.Helper()
+	o, err := s.db.Stores().Orders.GetOrder(context.Background(), 1, orderID)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return o.Status
+}
+
+func TestPaymentWebhookRejected(t *testing.T) {
+	s := newTestServer(t)
+	s.login("alice")
+	_, tx := s.payPending()
+	body := `{"id":"evt_1","type":"payment.succeeded","transaction_id":"` + tx + `"}`
+	now := time.Now()
+
+	tests := []struct {
+		name string
+		body string
+		sig  string
+		code int
+	}{
+		{"no signature", body, "", 401},
+		{"tampered body", `{"id":"evt_1","type":"payment.succeeded","transaction_id":"mock_other"}`, payment.SignWebhook([]byte(testWebhookSecret), []byte(body), now), 401},
+		{"stale timestamp", body, payment.SignWebhook([]byte(testWebhookSecret), []byte(body), now.Add(-time.Hour)), 401},
+		{"wrong secret", body, payment.SignWebhook([]byte("other"), []byte(body), now), 401},
+		{"malformed event", `{"type":"payment.succeeded"}`, payment.SignWebhook([]byte(testWebhookSecret), []byte(`{"type":"payment.succeeded"}`), now), 400},
+		{"unknown transaction", `{"id":"evt_2","type":"payment.succeeded","transaction_id":"mock_unknown"}`,
+			payment.SignWebhook([]byte(testWebhookSecret), []byte(`{"id":"evt_2","type":"payment.succeeded","transaction_id":"mock_unknown"}`), now), 404},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.expect(s.do("POST", "/api/payment/webhook", tt.body, payment.SignatureHeader, tt.sig), tt.code)
+		})
+	}
+	if st := s.orderStatus(1); st != orderstate.Pending {
+		t.Fatalf("order status after rejected webhooks = %s, want pending", st)
+	}
+	// 被拒绝的通知没有记录，渠道重试时正常处理
+	if body := s.webhook(time.Now(), "evt_1", "payment.succeeded", tx); body["duplicate"] != false {
+		t.Fatalf("retried webhook = %v", body)
+	}
+}
+
+func TestPaymentWebhookDuplicate(t *testing.T) {
+	s := newTestServer(t)
+	s.login("alice")
+	orderID, tx := s.payPending()
+
+	if body := s.webhook(time.Now(), "evt_1", "payment.succeeded", tx); body["duplicate"] != false {
+		t.Fatalf("first webhook = %v", body)
+	}
+	if body := s.webhook(time.Now(), "evt_1", "payment.succeeded", tx); body["duplicate"] != true {
+		t.Fatalf("repeated webhook = %v", body)
+	}
+	// 结算后的失败通知只记录，不改变支付
+	s.webhook(time.Now(), "evt_2", "payment.failed", tx)
+	if st := s.orderStatus(orderID); st != orderstate.ToShip {
+		t.Fatalf("order status = %s, want toship", st)
+	}
+	p := s.expect(s.do("GET", fmt.Sprintf("/api/order/payment?order_id=%d", orderID), ""), 200)
+	if p["status"] != "succeeded" || p["paid"] != true {
+		t.Fatalf("payment = %v", p)
+	}
+	history, err := s.db.Stores().Orders.OrderHistory(context.Background(), orderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("history = %+v, want create and pay only", history)
+	}
+}
+
+func TestPaymentWebhookConcurrent(t *testing.T) {
+	s := newTestServer(t)
+	s.login("alice")
+	orderID, tx := s.payPending()
+
+	const n = 8
+	body := `{"id":"evt_1","type":"payment.succeeded","transaction_id":"` + tx + `"}`
+	sig := payment.SignWebhook([]byte(testWebhookSecret), []byte(body), time.Now())
+	codes := make([]int, n)
+	duplicates := make([]string, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := s.serve("POST", "/api/payment/webhook", body, nil, payment.SignatureHeader, sig)
+			codes[i], duplicates[i] = w.Code, w.Body.String()
+		}(i)
+	}
+	wg.Wait()
+
+	first := 0
+	for i := range codes {
+		if codes[i] != 200 {
+			t.Fatalf("delivery %d status = %d, body %s", i, codes[i], duplicates[i])
+		}
+		if duplicates[i] == `{"duplicate":false,"received":true}` {
+			first++
+		}
+	}
+	if first != 1 {
+		t.Fatalf("%d deliveries processed, want 1: %v", first, duplicates)
+	}
+	if st := s.orderStatus(orderID); st != orderstate.ToShip {
+		t.Fatalf("order status = %s, want toship", st)
+	}
+	if got := s.db.ModelStock(10); got != 4 {
+		t.Fatalf("stock = %d, want 4", got)
+	}
+}
+
+func TestPaymentWebhookLateSuccess(t *testing.T) {
+	tests := []struct {
+		name       string
+		cancel     bool
+		wantOrder  orderstate.Status
+		wantPaid   bool
+		wantRefund bool
+	}{
+		{"order still pending is paid", false, orderstate.ToShip, true, false},
+		{"cancelled order is marked for refund", true, orderstate.Cancelled, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.login("alice")
+			orderID, tx := s.payPending()
+			s.webhook(time.Now(), "evt_1", "payment.failed", tx)
+			if tt.cancel {
+				if err := s.db.Stores().Orders.CancelOrder(context.Background(), orderID, orderstate.ActorSystem, "超时"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// 失败后渠道又通知成功：不能只记录通知而忽略已扣的款
+			later := time.Now().Add(2 * time.Hour)
+			s.mock.SetClock(func() time.Time { return later })
+			s.webhook(later, "evt_2", "payment.succeeded", tx)
+			if st := s.orderStatus(orderID); st != tt.wantOrder {
+				t.Fatalf("order status = %s, want %s", st, tt.wantOrder)
+			}
+			p := s.expect(s.do("GET", fmt.Sprintf("/api/order/payment?order_id=%d", orderID), ""), 200)
+			if p["status"] != "succeeded" || p["paid"] != tt.wantPaid || p["needs_refund"] != tt.wantRefund {
+				t.Fatalf("payment = %v", p)
+			}
+			n, err := s.svc.Payments.RefundUnmatched(context.Background(), 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := map[bool]int{true: 1, false: 0}[tt.wantRefund]; n != want {
+				t.Fatalf("RefundUnmatched = %d, want %d", n, want)
+			}
+		})
+	}
+}
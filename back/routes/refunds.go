@@ -0,0 +1,226 @@
+package routes
+
+import (
+	"back/config"
+	"back/service"
+	"back/storage"
+	"back/store"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// refundImagePrefix 售后凭证图片 key 前缀，按用户 ID 分目录，提交申请时据此确认图片由本人上传
+func refundImagePrefix(userID int) string {
+	return "refunds/" + strconv.Itoa(userID) + "/"
+}
+
+// ownsRefundImage 判断 key 是否为该用户上传的售后凭证图片
+func ownsRefundImage(userID int, key string) bool {
+	return storage.ValidKey(key) && strings.HasPrefix(key, refundImagePrefix(userID))
+}
+
+// 售后凭证图片上传接口，返回的 key 用于提交退款申请，url 仅用于预览
+func RegisterRefundImageRoute(r *gin.RouterGroup, cfg *config.Config, files storage.Storage) {
+	r.POST("/refunds/images", func(c *gin.Context) {
+		user := currentUser(c)
+		img, err := saveImage(c, "image", cfg.Upload, files, refundImagePrefix(user.ID))
+		if err != nil {
+			uploadError(c, err, cfg.Upload.MaxSize)
+			return
+		}
+		c.JSON(200, gin.H{
+			"message":   "上传成功",
+			"key":       img.Key,
+			"url":       storage.Resolve(files, img.Key),
+			"thumbnail": storage.Resolve(files, img.ThumbKey),
+		})
+	})
+}
+
+// 退款申请接口：按订单明细申请仅退款（refund）或退货退款（return），以及查询自己的申请
+func RegisterOrderRefundRoutes(r *gin.RouterGroup, refunds *service.RefundService, files storage.Storage) {
+	type RefundRequest struct {
+		OrderID     int      `json:"order_id"`
+		OrderItemID int      `json:"order_item_id"`
+		Type        string   `json:"type"`
+		Reason      string   `json:"reason"`
+		Images      []string `json:"images"` // 通过 /refunds/images 上传得到的 key
+	}
+	r.POST("/order/refund", func(c *gin.Context) {
+		user := currentUser(c)
+		var req RefundRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "参数错误"})
+			return
+		}
+		for _, key := range req.Images {
+			if !ownsRefundImage(user.ID, key) {
+				c.JSON(400, gin.H{"error": "图片无效，请重新上传"})
+				return
+			}
+		}
+		refund, err := refunds.Request(c.Request.Context(), user.ID, service.RefundInput{
+			OrderID:     req.OrderID,
+			OrderItemID: req.OrderItemID,
+			Type:        req.Type,
+			Reason:      req.Reason,
+			ImageKeys:   req.Images,
+		})
+		switch {
+		case errors.Is(err, service.ErrInvalidInput):
+			c.JSON(400, gin.H{"error": "类型须为 refund 或 return，原因不能为空且不超过 200 字，图片不超过 6 张"})
+		case errors.Is(err, store.ErrNotFound):
+			c.JSON(404, gin.H{"error": "订单或商品不存在"})
+		case errors.Is(err, service.ErrNotRefundable):
+			c.JSON(409, gin.H{"error": "订单当前状态不可申请该类型的售后"})
+		case errors.Is(err, service.ErrRefundExists):
+			c.JSON(409, gin.H{"error": "该商品已申请过退款"})
+		case err != nil:
+			dbError(c, err, "申请退款失败")
+		default:
+			c.JSON(200, refundJSON(files, refund))
+		}
+	})
+
+	r.GET("/order/refunds", func(c *gin.Context) {
+		user := currentUser(c)
+		orderID, err := queryInt(c, "order_id")
+		var page int
+		if err == nil {
+			page, err = queryInt(c, "page")
+		}
+		if err != nil {
+			c.JSON(400, gin.H{"error": "参数错误"})
+			return
+		}
+		list, err := refunds.List(c.Request.Context(), user.ID, orderID, page)
+		if errors.Is(err, service.ErrInvalidInput) {
+			c.JSON(400, gin.H{"error": "参数错误"})
+			return
+		}
+		if err != nil {
+			dbError(c, err, "数据库错误")
+			return
+		}
+		c.JSON(200, gin.H{"refunds": refundsJSON(files, list.Items), "total": list.Total})
+	})
+}
+
+// 售后处理接口（员工）：处理队列、同意（归还库存并原路退款）、拒绝
+func RegisterRefundReviewRoutes(r *gin.RouterGroup, refunds *service.RefundService, files storage.Storage) {
+	r.GET("/refunds", func(c *gin.Context) {
+		page, err := queryInt(c, "page")
+		if err != nil {
+			c.JSON(400, gin.H{"error": "参数错误"})
+			return
+		}
+		list, err := refunds.Queue(c.Request.Context(), c.Query("status"), page)
+		if errors.Is(err, service.ErrInvalidInput) {
+			c.JSON(400, gin.H{"error": "参数错误"})
+			return
+		}
+		if err != nil {
+			dbError(c, err, "数据库错误")
+			return
+		}
+		items := refundsJSON(files, list.Items)
+		for i, rf := range list.Items {
+			items[i]["user_id"] = rf.UserID
+		}
+		c.JSON(200, gin.H{"refunds": items, "total": list.Total})
+	})
+
+	type ReviewRequest struct {
+		Note string `json:"note"`
+	}
+	// bind 解析申请 ID 和可选的请求体
+	bind := func(c *gin.Context) (int, string, bool) {
+		id, err := strconv.Atoi(c.Param("id"))
+		var req ReviewRequest
+		if err == nil && c.Request.ContentLength != 0 {
+			err = c.ShouldBindJSON(&req)
+		}
+		if err != nil {
+			c.JSON(400, gin.H{"error": "参数错误"})
+			return 0, "", false
+		}
+		return id, req.Note, true
+	}
+
+	r.POST("/refunds/:id/approve", func(c *gin.Context) {
+		user := currentUser(c)
+		id, note, ok := bind(c)
+		if !ok {
+			return
+		}
+		refund, err := refunds.Approve(c.Request.Context(), id, note, "staff:"+user.Username)
+		switch {
+		case errors.Is(err, service.ErrInvalidInput):
+			c.JSON(400, gin.H{"error": "备注不超过 200 字"})
+		case errors.Is(err, store.ErrNotFound):
+			c.JSON(404, gin.H{"error": "退款申请不存在"})
+		case errors.Is(err, service.ErrRefundProcessed):
+			c.JSON(409, gin.H{"error": "退款申请已处理"})
+		case errors.Is(err, service.ErrNoSettledPayment):
+			c.JSON(409, gin.H{"error": "订单没有成功的支付记录，无法原路退款"})
+		case err != nil:
+			// 申请已同意但渠道退款未完成，可稍后重试
+			dbError(c, err, "退款失败，请稍后重试")
+		default:
+			c.JSON(200, refundJSON(files, refund))
+		}
+	})
+
+	r.POST("/refunds/:id/reject", func(c *gin.Context) {
+		user := currentUser(c)
+		id, note, ok := bind(c)
+		if !ok {
+			return
+		}
+		err := refunds.Reject(c.Request.Context(), id, note, "staff:"+user.Username)
+		switch {
+		case errors.Is(err, service.ErrInvalidInput):
+			c.JSON(400, gin.H{"error": "请填写拒绝原因，不超过 200 字"})
+		case errors.Is(err, store.ErrNotFound):
+			c.JSON(404, gin.H{"error": "退款申请不存在"})
+		case errors.Is(err, service.ErrRefundProcessed):
+			c.JSON(409, gin.H{"error": "退款申请已处理"})
+		case err != nil:
+			dbError(c, err, "数据库错误")
+		default:
+			c.JSON(200, gin.H{"success": true, "message": "已拒绝"})
+		}
+	})
+}
+
+// refundJSON 退款申请的响应格式
+func refundJSON(files storage.Storage, r *store.Refund) gin.H {
+	return gin.H{
+		"id":                 r.ID,
+		"order_id":           r.OrderID,
+		"order_item_id":      r.OrderItemID,
+		"title":              r.Title,
+		"model_name":         r.ModelName,
+		"quantity":           r.Quantity,
+		"type":               r.Type,
+		"reason":             r.Reason,
+		"images":             storage.ResolveAll(files, r.ImageKeys),
+		"amount":             r.Amount,
+		"status":             r.Status,
+		"review_note":        r.ReviewNote,
+		"provider_refund_id": r.ProviderRefundID,
+		"created_at":         r.CreatedAt.Format("2006-01-02 15:04:05"),
+		"updated_at":         r.UpdatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
+
+func refundsJSON(files storage.Storage, refunds []store.Refund) []gin.H {
+	list := []gin.H{}
+	for i := range refunds {
+		list = append(list, refundJSON(files, &refunds[i]))
+	}
+	return list
+}
@@ -0,0 +1,121 @@
+package routes
+
+import (
+	"back/config"
+	"back/service"
+	"back/storage"
+	"back/store"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// reviewImagePrefix 评价图片 key 前缀，按用户 ID 分目录，提交评价时据此确认图片由本人上传
+func reviewImagePrefix(userID int) string {
+	return "reviews/" + strconv.Itoa(userID) + "/"
+}
+
+// ownsReviewImage 判断 key 是否为该用户上传的评价图片
+func ownsReviewImage(userID int, key string) bool {
+	return storage.ValidKey(key) && strings.HasPrefix(key, reviewImagePrefix(userID))
+}
+
+// 评价图片上传接口，与头像走同一套上传流程，返回的 key 用于提交评价，url 仅用于预览
+func RegisterReviewImageRoute(r *gin.RouterGroup, cfg *config.Config, files storage.Storage) {
+	r.POST("/reviews/images", func(c *gin.Context) {
+		user := currentUser(c)
+		img, err := saveImage(c, "image", cfg.Upload, files, reviewImagePrefix(user.ID))
+		if err != nil {
+			uploadError(c, err, cfg.Upload.MaxSize)
+			return
+		}
+		c.JSON(200, gin.H{
+			"message":   "上传成功",
+			"key":       img.Key,
+			"url":       storage.Resolve(files, img.Key),
+			"thumbnail": storage.Resolve(files, img.ThumbKey),
+		})
+	})
+}
+
+// 评价“有用”投票接口：POST 标记，DELETE 取消
+func RegisterReviewVoteRoute(r *gin.RouterGroup, reviews *service.ReviewService) {
+	vote := func(helpful bool) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			user := currentUser(c)
+			id, err := strconv.Atoi(c.Param("id"))
+			if err != nil {
+				c.JSON(400, gin.H{"error": "参数错误"})
+				return
+			}
+			count, err := reviews.Vote(c.Request.Context(), id, user.ID, helpful)
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				c.JSON(404, gin.H{"error": "评价不存在"})
+			case errors.Is(err, service.ErrOwnReview):
+				c.JSON(400, gin.H{"error": "不能给自己的评价投票"})
+			case err != nil:
+				dbError(c, err, "数据库错误")
+			default:
+				c.JSON(200, gin.H{"helpful_count": count})
+			}
+		}
+	}
+	r.POST("/reviews/:id/helpful", vote(true))
+	r.DELETE("/reviews/:id/helpful", vote(false))
+}
+
+// 评价审核接口（员工）：审核队列、变更审核状态
+func RegisterReviewModerationRoutes(r *gin.RouterGroup, reviews *service.ReviewService, files storage.Storage) {
+	r.GET("/reviews", func(c *gin.Context) {
+		page, err := queryInt(c, "page")
+		if err != nil {
+			c.JSON(400, gin.H{"error": "参数错误"})
+			return
+		}
+		list, err := reviews.ModerationQueue(c.Request.Context(), c.Query("status"), page)
+		if errors.Is(err, service.ErrInvalidInput) {
+			c.JSON(400, gin.H{"error": "参数错误"})
+			return
+		}
+		if err != nil {
+			dbError(c, err, "数据库错误")
+			return
+		}
+		items := reviewsJSON(files, list.Items)
+		for i, r := range list.Items {
+			items[i]["product_id"] = r.ProductID
+			items[i]["status"] = r.Status
+		}
+		c.JSON(200, gin.H{"reviews": items, "total": list.Total})
+	})
+
+	type ModerateRequest struct {
+		Status string `json:"status"`
+	}
+	r.POST("/reviews/:id/moderate", func(c *gin.Context) {
+		user := currentUser(c)
+		id, err := strconv.Atoi(c.Param("id"))
+		var req ModerateRequest
+		if err == nil {
+			err = c.ShouldBindJSON(&req)
+		}
+		if err != nil {
+			c.JSON(400, gin.H{"error": "参数错误"})
+			return
+		}
+		err = reviews.Moderate(c.Request.Context(), id, req.Status, "staff:"+user.Username)
+		switch {
+		case errors.Is(err, service.ErrInvalidInput):
+			c.JSON(400, gin.H{"error": "审核状态只能为 pending、approved 或 hidden"})
+		case errors.Is(err, store.ErrNotFound):
+			c.JSON(404, gin.H{"error": "评价不存在"})
+		case err != nil:
+			dbError(c, err, "数据库错误")
+		default:
+			c.JSON(200, gin.H{"success": true, "message": "已更新"})
+		}
+	})
+}
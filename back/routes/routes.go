@@ -1,15 +1,19 @@
 package routes
 
 import (
-	"context"
+	"back/config"
+	"back/service"
+	"back/storage"
+	"back/store"
+	"errors"
 	"fmt"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
-	"github.com/jackc/pgx/v4"
-	"github.com/jackc/pgx/v4/pgxpool"
+	"math"
+	"strconv"
 )
 
-func RegisterRoutes(r *gin.Engine, pool *pgxpool.Pool) {
+func RegisterRoutes(r *gin.Engine, svc *service.Services, cfg *config.Config, files storage.Storage) {
 	r.GET("/ping", func(c *gin.Context) {
 		c.JSON(200, gin.H{"message": "pong"})
 	})
@@ -29,28 +33,22 @@ func RegisterRoutes(r *gin.Engine, pool *pgxpool.Pool) {
 				c.JSON(400, gin.H{"error": "参数错误"})
 				return
 			}
-			if req.Username == "" || req.Password == "" {
-				c.JSON(400, gin.H{"error": "用户名和密码不能为空"})
-				return
-			}
-			var exists bool
-			err := pool.QueryRow(context.Background(), "SELECT EXISTS(SELECT 1 FROM users WHERE username=$1)", req.Username).Scan(&exists)
-			if err != nil {
-				c.JSON(500, gin.H{"error": "数据库错误"})
-				return
-			}
-			if exists {
+			_, err := svc.Users.Register(c.Request.Context(), service.RegisterInput{
+				Username: req.Username,
+				Password: req.Password,
+				Nickname: req.Nickname,
+				Address:  req.Address,
+			})
+			switch {
+			case errors.Is(err, service.ErrInvalidInput):
+				c.JSON(400, gin.H{"error": "用户名和密码不能为空，密码不能超过 72 字节"})
+			case errors.Is(err, service.ErrUsernameTaken):
 				c.JSON(400, gin.H{"error": "用户名已存在"})
-				return
+			case err != nil:
+				dbError(c, err, "注册失败")
+			default:
+				c.JSON(200, gin.H{"message": "注册成功"})
 			}
-			_, err = pool.Exec(context.Background(),
-				"INSERT INTO users (username, password, nickname, address) VALUES ($1, $2, $3, $4)",
-				req.Username, req.Password, req.Nickname, req.Address)
-			if err != nil {
-				c.JSON(500, gin.H{"error": "注册失败"})
-				return
-			}
-			c.JSON(200, gin.H{"message": "注册成功"})
 		})
 
 		api.POST("/login", func(c *gin.Context) {
@@ -63,497 +61,431 @@ func RegisterRoutes(r *gin.Engine, pool *pgxpool.Pool) {
 				c.JSON(400, gin.H{"error": "参数错误"})
 				return
 			}
-			var dbPassword string
-			err := pool.QueryRow(context.Background(), "SELECT password FROM users WHERE username=$1", req.Username).Scan(&dbPassword)
-			if err != nil {
+			user, err := svc.Users.Login(c.Request.Context(), req.Username, req.Password)
+			if errors.Is(err, service.ErrInvalidCredentials) {
 				c.JSON(400, gin.H{"error": "用户名或密码错误"})
 				return
 			}
-			if dbPassword != req.Password {
-				c.JSON(400, gin.H{"error": "用户名或密码错误"})
+			if err != nil {
+				dbError(c, err, "数据库错误")
 				return
 			}
 			session := sessions.Default(c)
-			session.Set("user", req.Username)
+			session.Set("user", user.Username)
 			session.Save()
 			c.JSON(200, gin.H{"message": "登录成功"})
 		})
 
-		api.GET("/user/address", func(c *gin.Context) {
-			session := sessions.Default(c)
-			username, ok := session.Get("user").(string)
-			if !ok || username == "" {
-				c.JSON(401, gin.H{"error": "未登录"})
-				return
-			}
-			var address string
-			err := pool.QueryRow(context.Background(), "SELECT address FROM users WHERE username=$1", username).Scan(&address)
+		// 支付渠道异步通知，由签名校验来源，不需要登录
+		RegisterPaymentWebhookRoute(api, svc.Payments)
+
+		// 以下接口需要登录，当前用户由 requireUser 解析后放入 gin.Context
+		authed := api.Group("", requireUser(svc.Users))
+
+		// 旧版单一地址字段，已废弃：下单使用地址簿，地址簿为空时才会导入该字段，见 AddressService.importLegacyAddress
+		authed.GET("/user/address", func(c *gin.Context) {
+			user := currentUser(c)
+			profile, err := svc.Users.Profile(c.Request.Context(), user.ID)
 			if err != nil {
-				c.JSON(500, gin.H{"error": "数据库错误"})
+				dbError(c, err, "数据库错误")
 				return
 			}
-			c.JSON(200, gin.H{"address": address})
+			c.JSON(200, gin.H{"address": profile.Address})
 		})
 
-		api.POST("/user/address", func(c *gin.Context) {
-			session := sessions.Default(c)
-			username, ok := session.Get("user").(string)
-			if !ok || username == "" {
-				c.JSON(401, gin.H{"error": "未登录"})
-				return
-			}
+		authed.POST("/user/address", func(c *gin.Context) {
+			user := currentUser(c)
 			type AddrReq struct {
 				Address string `json:"address"`
 			}
 			var req AddrReq
-			if err := c.ShouldBindJSON(&req); err != nil || req.Address == "" {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(400, gin.H{"error": "参数错误"})
+				return
+			}
+			err := svc.Users.UpdateAddress(c.Request.Context(), user.ID, req.Address)
+			if errors.Is(err, service.ErrInvalidInput) {
 				c.JSON(400, gin.H{"error": "参数错误"})
 				return
 			}
-			_, err := pool.Exec(context.Background(), "UPDATE users SET address=$1 WHERE username=$2", req.Address, username)
 			if err != nil {
-				c.JSON(500, gin.H{"error": "保存失败"})
+				dbError(c, err, "保存失败")
 				return
 			}
 			c.JSON(200, gin.H{"message": "保存成功"})
 		})
 
+		// 收货地址簿，下单时按 address_id 选择
+		RegisterAddressRoutes(authed, svc.Addresses)
+
 		// 头像上传接口
-		api.POST("/user/avatar", func(c *gin.Context) {
-			session := sessions.Default(c)
-			username, ok := session.Get("user").(string)
-			if !ok || username == "" {
-				c.JSON(401, gin.H{"error": "未登录"})
-				return
-			}
-			file, err := c.FormFile("avatar")
+		authed.POST("/user/avatar", func(c *gin.Context) {
+			user := currentUser(c)
+			img, err := saveImage(c, "avatar", cfg.Upload, files, avatarPrefix(user.ID))
 			if err != nil {
-				c.JSON(400, gin.H{"error": "未选择文件"})
+				uploadError(c, err, cfg.Upload.MaxSize)
 				return
 			}
-			// 生成唯一文件名
-			filename := "avatar_" + username + "_" + file.Filename
-			path := "uploads/" + filename
-			if err := c.SaveUploadedFile(file, path); err != nil {
-				c.JSON(500, gin.H{"error": "保存失败"})
-				return
-			}
-			// 更新数据库
-			_, err = pool.Exec(context.Background(), "UPDATE users SET avatar=$1 WHERE username=$2", "/"+path, username)
+			// 更新数据库，成功后删除旧头像
+			old, err := svc.Users.UpdateAvatar(c.Request.Context(), user.ID, img.Key)
 			if err != nil {
-				c.JSON(500, gin.H{"error": "数据库错误"})
+				dbError(c, err, "数据库错误")
 				return
 			}
-			c.JSON(200, gin.H{"message": "上传成功", "avatar": "/" + path})
+			if old != img.Key {
+				removeUpload(c.Request.Context(), files, old)
+			}
+			c.JSON(200, gin.H{
+				"message":   "上传成功",
+				"avatar":    storage.Resolve(files, img.Key),
+				"thumbnail": storage.Resolve(files, img.ThumbKey),
+			})
 		})
 
-		// 静态文件服务，提供头像图片访问
-		r.Static("/uploads", "uploads")
+		// 本地存储时由本服务校验签名后提供图片访问
+		if local, ok := files.(*storage.Local); ok {
+			r.GET(local.Prefix()+"*key", gin.WrapH(local))
+		}
 
 		// 用户信息接口
-		api.GET("/user/profile", func(c *gin.Context) {
-			session := sessions.Default(c)
-			username, ok := session.Get("user").(string)
-			if !ok || username == "" {
-				c.JSON(401, gin.H{"success": false, "message": "未登录"})
+		authed.GET("/user/profile", func(c *gin.Context) {
+			user := currentUser(c)
+			profile, err := svc.Users.Profile(c.Request.Context(), user.ID)
+			if err != nil {
+				dbError(c, err, "数据库错误")
 				return
 			}
-			var nickname, address, avatar string
-			err := pool.QueryRow(context.Background(), "SELECT nickname, address, avatar FROM users WHERE username=$1", username).Scan(&nickname, &address, &avatar)
-			if err != nil {
-				_ = pool.QueryRow(context.Background(), "SELECT address, avatar FROM users WHERE username=$1", username).Scan(&address, &avatar)
-				nickname = username
+			nickname := profile.Nickname
+			if nickname == "" {
+				nickname = profile.Username
 			}
 			c.JSON(200, gin.H{
 				"success":  true,
 				"nickname": nickname,
-				"address":  address,
-				"avatar":   avatar,
+				"address":  profile.Address,
+				"avatar":   storage.Resolve(files, profile.Avatar),
 			})
 		})
 
 		// 昵称修改接口
-		api.POST("/user/nickname", func(c *gin.Context) {
-			session := sessions.Default(c)
-			username, ok := session.Get("user").(string)
-			if !ok || username == "" {
-				c.JSON(401, gin.H{"success": false, "message": "未登录"})
-				return
-			}
+		authed.POST("/user/nickname", func(c *gin.Context) {
+			user := currentUser(c)
 			type NickReq struct {
 				Nickname string `json:"nickname"`
 			}
 			var req NickReq
-			if err := c.ShouldBindJSON(&req); err != nil || req.Nickname == "" {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(400, gin.H{"success": false, "message": "参数错误"})
+				return
+			}
+			err := svc.Users.UpdateNickname(c.Request.Context(), user.ID, req.Nickname)
+			if errors.Is(err, service.ErrInvalidInput) {
 				c.JSON(400, gin.H{"success": false, "message": "参数错误"})
 				return
 			}
-			_, err := pool.Exec(context.Background(), "UPDATE users SET nickname=$1 WHERE username=$2", req.Nickname, username)
 			if err != nil {
-				c.JSON(500, gin.H{"success": false, "message": "保存失败"})
+				dbError(c, err, "保存失败")
 				return
 			}
 			c.JSON(200, gin.H{"success": true, "message": "保存成功"})
 		})
 
-		// 商品列表接口（支持分类筛选，返回主信息+首图+最低价+销量）
+		// 商品列表接口（支持分类、价格区间、有货筛选，多种排序，游标分页）
 		api.GET("/products", func(c *gin.Context) {
-			category := c.Query("category")
-			var rows pgx.Rows
+			category, ok := categoryFilter(c)
+			in := service.ListInput{
+				Category: category,
+				Sort:     c.Query("sort"),
+				Cursor:   c.Query("cursor"),
+				InStock:  c.Query("in_stock") == "1" || c.Query("in_stock") == "true",
+			}
 			var err error
-			if category != "" {
-				rows, err = pool.Query(context.Background(), `SELECT p.id, p.title, p.category, COALESCE(MIN(m.price),0) as min_price, COALESCE(img.url,''), COALESCE(SUM(m.stock),0)
-					FROM products p
-					LEFT JOIN product_models m ON p.id = m.product_id
-					LEFT JOIN product_images img ON p.id = img.product_id AND img.id = (
-						SELECT id FROM product_images WHERE product_id = p.id LIMIT 1)
-					WHERE p.category=$1
-					GROUP BY p.id, img.url
-					ORDER BY p.id DESC`, category)
-			} else {
-				rows, err = pool.Query(context.Background(), `SELECT p.id, p.title, p.category, COALESCE(MIN(m.price),0) as min_price, COALESCE(img.url,''), COALESCE(SUM(m.stock),0)
-					FROM products p
-					LEFT JOIN product_models m ON p.id = m.product_id
-					LEFT JOIN product_images img ON p.id = img.product_id AND img.id = (
-						SELECT id FROM product_images WHERE product_id = p.id LIMIT 1)
-					GROUP BY p.id, img.url
-					ORDER BY p.id DESC`)
+			if in.MinPrice, err = queryFloat(c, "min_price"); err == nil {
+				in.MaxPrice, err = queryFloat(c, "max_price")
+			}
+			if err == nil && c.Query("limit") != "" {
+				_, err = fmt.Sscanf(c.Query("limit"), "%d", &in.Limit)
+			}
+			if err != nil || !ok {
+				c.JSON(400, gin.H{"error": "参数错误"})
+				return
+			}
+			list, err := svc.Products.List(c.Request.Context(), in)
+			if errors.Is(err, service.ErrInvalidInput) {
+				c.JSON(400, gin.H{"error": "参数错误"})
+				return
+			}
+			if errors.Is(err, store.ErrNotFound) {
+				c.JSON(404, gin.H{"error": "分类不存在"})
+				return
 			}
 			if err != nil {
-				c.JSON(500, gin.H{"error": "数据库错误"})
+				dbError(c, err, "数据库错误")
 				return
 			}
-			defer rows.Close()
 			products := []gin.H{}
-			for rows.Next() {
-				var id int
-				var title, category, img string
-				var minPrice float64
-				var stock int
-				if err := rows.Scan(&id, &title, &category, &minPrice, &img, &stock); err == nil {
-					products = append(products, gin.H{
-						"id": id, "title": title, "category": category, "price": minPrice, "img": img, "stock": stock,
-					})
+			for _, p := range list.Items {
+				products = append(products, gin.H{
+					"id": p.ID, "title": p.Title, "category_id": p.CategoryID, "category": p.Category, "price": p.MinPrice, "img": storage.Resolve(files, p.Img), "stock": p.Stock,
+					"sales": p.Sales, "rating": p.Rating, "review_count": p.ReviewCount,
+				})
+			}
+			c.JSON(200, gin.H{"products": products, "total": list.Total, "next_cursor": list.NextCursor})
+		})
+
+		// 商品搜索接口（标题、描述全文/模糊匹配，可叠加分类筛选，按相关度排序并返回高亮片段）
+		api.GET("/products/search", func(c *gin.Context) {
+			limit := 0
+			if v := c.Query("limit"); v != "" {
+				if _, err := fmt.Sscanf(v, "%d", &limit); err != nil {
+					c.JSON(400, gin.H{"error": "参数错误"})
+					return
 				}
 			}
+			category, ok := categoryFilter(c)
+			if !ok {
+				c.JSON(400, gin.H{"error": "参数错误"})
+				return
+			}
+			list, err := svc.Products.Search(c.Request.Context(), c.Query("q"), category, limit)
+			if errors.Is(err, service.ErrInvalidInput) {
+				c.JSON(400, gin.H{"error": "搜索词不能为空且不超过 64 个字符、5 个关键词"})
+				return
+			}
+			if errors.Is(err, store.ErrNotFound) {
+				c.JSON(404, gin.H{"error": "分类不存在"})
+				return
+			}
+			if err != nil {
+				dbError(c, err, "数据库错误")
+				return
+			}
+			products := []gin.H{}
+			for _, p := range list {
+				products = append(products, gin.H{
+					"id": p.ID, "title": p.Title, "category_id": p.CategoryID, "category": p.Category, "price": p.MinPrice, "img": storage.Resolve(files, p.Img), "stock": p.Stock,
+					"highlight": gin.H{"title": p.TitleHighlight, "description": p.DescriptionHighlight},
+					"score":     p.Rank,
+				})
+			}
 			c.JSON(200, gin.H{"products": products})
 		})
 
-		// 商品详情接口（含图片、型号、评价）
+		RegisterCategoriesRoute(api, svc.Categories)
+
+		// 商品详情接口（含图片、型号、评价、销量和评分）
 		api.GET("/products/:id", func(c *gin.Context) {
-			idStr := c.Param("id")
 			var id int
-			if _, err := fmt.Sscanf(idStr, "%d", &id); err != nil {
+			if _, err := fmt.Sscanf(c.Param("id"), "%d", &id); err != nil {
 				c.JSON(400, gin.H{"error": "参数错误"})
 				return
 			}
-			var title, category, description string
-			err := pool.QueryRow(context.Background(), "SELECT title, category, description FROM products WHERE id=$1", id).Scan(&title, &category, &description)
-			if err != nil {
+			p, err := svc.Products.Detail(c.Request.Context(), id)
+			if errors.Is(err, store.ErrNotFound) {
 				c.JSON(404, gin.H{"error": "商品不存在"})
 				return
 			}
-			// 图片
-			imgRows, _ := pool.Query(context.Background(), "SELECT url FROM product_images WHERE product_id=$1", id)
-			imgs := []string{}
-			for imgRows.Next() {
-				var url string
-				if err := imgRows.Scan(&url); err == nil {
-					imgs = append(imgs, url)
-				}
+			if err != nil {
+				dbError(c, err, "数据库错误")
+				return
 			}
-			imgRows.Close()
-			// 型号
-			modelRows, _ := pool.Query(context.Background(), "SELECT id, model_name, price, stock FROM product_models WHERE product_id=$1", id)
 			models := []gin.H{}
-			for modelRows.Next() {
-				var mid int
-				var mname string
-				var price float64
-				var stock int
-				if err := modelRows.Scan(&mid, &mname, &price, &stock); err == nil {
-					models = append(models, gin.H{"id": mid, "name": mname, "price": price, "stock": stock})
-				}
-			}
-			modelRows.Close()
-			// 评价
-			reviewRows, _ := pool.Query(context.Background(), "SELECT username, rating, content, created_at, model_id FROM product_reviews WHERE product_id=$1 ORDER BY created_at DESC LIMIT 10", id)
-			reviews := []gin.H{}
-			for reviewRows.Next() {
-				var username, content string
-				var rating, modelId int
-				var createdAt interface{}
-				if err := reviewRows.Scan(&username, &rating, &content, &createdAt, &modelId); err == nil {
-					reviews = append(reviews, gin.H{"username": username, "rating": rating, "content": content, "created_at": createdAt, "model_id": modelId})
-				}
+			for _, m := range p.Models {
+				models = append(models, gin.H{"id": m.ID, "name": m.Name, "price": m.Price, "stock": m.Stock})
 			}
-			reviewRows.Close()
 			c.JSON(200, gin.H{
-				"id": id, "title": title, "category": category, "description": description,
-				"imgs": imgs, "models": models, "reviews": reviews,
+				"id": p.ID, "title": p.Title, "category_id": p.CategoryID, "category": p.Category, "description": p.Description,
+				"imgs": storage.ResolveAll(files, p.Images), "models": models, "reviews": reviewsJSON(files, p.Reviews),
+				"sales": p.Sales, "rating": p.Rating, "review_count": p.ReviewCount,
 			})
 		})
 
-		// 商品评论列表接口
+		// 商品评论列表接口（只含审核通过的评价，支持按最新/有用排序、星级筛选、分页）
 		api.GET("/products/:id/reviews", func(c *gin.Context) {
-			idStr := c.Param("id")
 			var id int
-			if _, err := fmt.Sscanf(idStr, "%d", &id); err != nil {
+			if _, err := fmt.Sscanf(c.Param("id"), "%d", &id); err != nil {
 				c.JSON(400, gin.H{"error": "参数错误"})
 				return
 			}
-			rows, err := pool.Query(context.Background(), "SELECT username, rating, content, created_at, model_id FROM product_reviews WHERE product_id=$1 ORDER BY created_at DESC", id)
+			in := service.ReviewListInput{Sort: c.Query("sort")}
+			var err error
+			if in.Rating, err = queryInt(c, "rating"); err == nil {
+				if in.Page, err = queryInt(c, "page"); err == nil {
+					in.PageSize, err = queryInt(c, "page_size")
+				}
+			}
 			if err != nil {
-				c.JSON(500, gin.H{"error": "数据库错误"})
+				c.JSON(400, gin.H{"error": "参数错误"})
 				return
 			}
-			defer rows.Close()
-			reviews := []gin.H{}
-			for rows.Next() {
-				var username, content string
-				var rating, modelId int
-				var createdAt interface{}
-				if err := rows.Scan(&username, &rating, &content, &createdAt, &modelId); err == nil {
-					reviews = append(reviews, gin.H{"username": username, "rating": rating, "content": content, "created_at": createdAt, "model_id": modelId})
-				}
-			}
-			c.JSON(200, gin.H{"reviews": reviews})
-		})
-
-		// 购物车列表接口
-		api.GET("/cart", func(c *gin.Context) {
-			session := sessions.Default(c)
-			username, ok := session.Get("user").(string)
-			if !ok || username == "" {
-				c.JSON(401, gin.H{"error": "未登录"})
+			page, err := svc.Reviews.ProductReviews(c.Request.Context(), id, in)
+			if errors.Is(err, service.ErrInvalidInput) {
+				c.JSON(400, gin.H{"error": "参数错误"})
 				return
 			}
-
-			var userID int
-			err := pool.QueryRow(context.Background(),
-				"SELECT id FROM users WHERE username=$1", username).Scan(&userID)
 			if err != nil {
-				fmt.Println("用户不存在：", err)
-				c.JSON(500, gin.H{"error": "用户不存在"})
+				dbError(c, err, "数据库错误")
 				return
 			}
+			c.JSON(200, gin.H{"reviews": reviewsJSON(files, page.Items), "total": page.Total})
+		})
 
-			query := `
-			SELECT c.id, c.product_id, c.model_id, c.quantity,
-			       p.title, p.category,
-			       m.model_name, m.price,
-			       COALESCE(img.url, '') AS img_url
-			FROM cart c
-			JOIN products p ON c.product_id = p.id
-			JOIN product_models m ON c.model_id = m.id
-			LEFT JOIN LATERAL (
-				SELECT url FROM product_images
-				WHERE product_id = p.id
-				ORDER BY id LIMIT 1
-			) img ON TRUE
-			WHERE c.user_id = $1
-			ORDER BY c.id DESC
-		`
-
-			rows, err := pool.Query(context.Background(), query, userID)
+		// 购物车列表接口
+		authed.GET("/cart", func(c *gin.Context) {
+			user := currentUser(c)
+			list, err := svc.Carts.List(c.Request.Context(), user.ID)
 			if err != nil {
-				fmt.Println("查询购物车失败：", err)
-				c.JSON(500, gin.H{"error": "数据库错误"})
+				dbError(c, err, "数据库错误")
 				return
 			}
-			defer rows.Close()
-
 			items := []gin.H{}
-
-			for rows.Next() {
-				var (
-					id, productID, modelID, qty        int
-					title, category, modelName, imgURL string
-					price                              float64
-				)
-
-				err := rows.Scan(&id, &productID, &modelID, &qty, &title, &category, &modelName, &price, &imgURL)
-				if err != nil {
-					fmt.Println("行解析错误：", err)
-					continue
-				}
-
+			for _, it := range list {
 				items = append(items, gin.H{
-					"id":         id,
-					"product_id": productID,
-					"model_id":   modelID,
-					"name":       title,
+					"id":         it.ID,
+					"product_id": it.ProductID,
+					"model_id":   it.ModelID,
+					"name":       it.Title,
 					"shop":       "官方旗舰店",
-					"img":        imgURL,
-					"model":      modelName,
-					"price":      price,
-					"qty":        qty,
+					"img":        storage.Resolve(files, it.Img),
+					"model":      it.ModelName,
+					"price":      it.Price,
+					"qty":        it.Quantity,
 				})
 			}
-
 			c.JSON(200, items)
 		})
 
 		// 购物车添加接口
-		api.POST("/cart", func(c *gin.Context) {
-			session := sessions.Default(c)
-			username, ok := session.Get("user").(string)
-			if !ok || username == "" {
-				c.JSON(401, gin.H{"error": "未登录"})
-				return
-			}
-			var userID int
-			err := pool.QueryRow(context.Background(), "SELECT id FROM users WHERE username=$1", username).Scan(&userID)
-			if err != nil {
-				c.JSON(500, gin.H{"error": "用户不存在"})
-				return
-			}
+		authed.POST("/cart", func(c *gin.Context) {
+			user := currentUser(c)
 			type AddCartReq struct {
 				ProductID int `json:"product_id"`
 				ModelID   int `json:"model_id"`
 				Qty       int `json:"qty"`
 			}
 			var req AddCartReq
-			if err := c.ShouldBindJSON(&req); err != nil || req.ProductID == 0 || req.ModelID == 0 || req.Qty < 1 {
+			if err := c.ShouldBindJSON(&req); err != nil {
 				c.JSON(400, gin.H{"error": "参数错误"})
 				return
 			}
-			// 查找是否已存在该商品型号
-			var existID int
-			err = pool.QueryRow(context.Background(), "SELECT id FROM cart WHERE user_id=$1 AND product_id=$2 AND model_id=$3", userID, req.ProductID, req.ModelID).Scan(&existID)
-			if err == nil {
-				// 已存在则更新数量
-				_, err = pool.Exec(context.Background(), "UPDATE cart SET quantity = quantity + $1, updated_at=now() WHERE id=$2", req.Qty, existID)
-				if err != nil {
-					c.JSON(500, gin.H{"error": "更新失败"})
-					return
-				}
-				c.JSON(200, gin.H{"message": "已更新数量"})
+			updated, err := svc.Carts.Add(c.Request.Context(), user.ID, req.ProductID, req.ModelID, req.Qty)
+			if errors.Is(err, service.ErrInvalidInput) {
+				c.JSON(400, gin.H{"error": "参数错误"})
 				return
 			}
-			// 不存在则插入
-			_, err = pool.Exec(context.Background(), "INSERT INTO cart (user_id, product_id, model_id, quantity) VALUES ($1, $2, $3, $4)", userID, req.ProductID, req.ModelID, req.Qty)
 			if err != nil {
-				c.JSON(500, gin.H{"error": "添加失败"})
+				dbError(c, err, "添加失败")
+				return
+			}
+			if updated {
+				c.JSON(200, gin.H{"message": "已更新数量"})
 				return
 			}
 			c.JSON(200, gin.H{"message": "添加成功"})
 		})
 
 		// 购物车数量修改接口
-		api.PUT("/cart", func(c *gin.Context) {
-			session := sessions.Default(c)
-			username, ok := session.Get("user").(string)
-			if !ok || username == "" {
-				c.JSON(401, gin.H{"error": "未登录"})
-				return
-			}
-			var userID int
-			err := pool.QueryRow(context.Background(), "SELECT id FROM users WHERE username=$1", username).Scan(&userID)
-			if err != nil {
-				c.JSON(500, gin.H{"error": "用户不存在"})
-				return
-			}
+		authed.PUT("/cart", func(c *gin.Context) {
+			user := currentUser(c)
 			type UpdateCartReq struct {
 				CartID int `json:"id"`
 				Qty    int `json:"qty"`
 			}
 			var req UpdateCartReq
-			if err := c.ShouldBindJSON(&req); err != nil || req.CartID == 0 || req.Qty < 1 {
+			if err := c.ShouldBindJSON(&req); err != nil {
 				c.JSON(400, gin.H{"error": "参数错误"})
 				return
 			}
-			// 校验该购物车项归属
-			var existID int
-			err = pool.QueryRow(context.Background(), "SELECT id FROM cart WHERE id=$1 AND user_id=$2", req.CartID, userID).Scan(&existID)
-			if err != nil {
+			err := svc.Carts.UpdateQuantity(c.Request.Context(), user.ID, req.CartID, req.Qty)
+			switch {
+			case errors.Is(err, service.ErrInvalidInput):
+				c.JSON(400, gin.H{"error": "参数错误"})
+			case errors.Is(err, store.ErrNotFound):
 				c.JSON(404, gin.H{"error": "购物车项不存在"})
-				return
-			}
-			_, err = pool.Exec(context.Background(), "UPDATE cart SET quantity=$1, updated_at=now() WHERE id=$2", req.Qty, req.CartID)
-			if err != nil {
-				c.JSON(500, gin.H{"error": "更新失败"})
-				return
+			case err != nil:
+				dbError(c, err, "更新失败")
+			default:
+				c.JSON(200, gin.H{"message": "数量已更新"})
 			}
-			c.JSON(200, gin.H{"message": "数量已更新"})
 		})
 
 		// 购物车移除接口
-		api.DELETE("/cart", func(c *gin.Context) {
-			session := sessions.Default(c)
-			username, ok := session.Get("user").(string)
-			if !ok || username == "" {
-				c.JSON(401, gin.H{"error": "未登录"})
-				return
-			}
-			var userID int
-			err := pool.QueryRow(context.Background(), "SELECT id FROM users WHERE username=$1", username).Scan(&userID)
-			if err != nil {
-				c.JSON(500, gin.H{"error": "用户不存在"})
-				return
-			}
+		authed.DELETE("/cart", func(c *gin.Context) {
+			user := currentUser(c)
 			type DelCartReq struct {
 				CartID int `json:"id"`
 			}
 			var req DelCartReq
-			if err := c.ShouldBindJSON(&req); err != nil || req.CartID == 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(400, gin.H{"error": "参数错误"})
+				return
+			}
+			err := svc.Carts.Remove(c.Request.Context(), user.ID, req.CartID)
+			if errors.Is(err, service.ErrInvalidInput) {
 				c.JSON(400, gin.H{"error": "参数错误"})
 				return
 			}
-			_, err = pool.Exec(context.Background(), "DELETE FROM cart WHERE id=$1 AND user_id=$2", req.CartID, userID)
 			if err != nil {
-				c.JSON(500, gin.H{"error": "删除失败"})
+				dbError(c, err, "删除失败")
 				return
 			}
 			c.JSON(200, gin.H{"message": "已移除"})
 		})
 
 		// 注册订单相关接口
-		RegisterOrderCountsRoute(r, pool)
-		RegisterOrderListRoute(r, pool)
-		RegisterOrderCreateRoute(r, pool)
-		RegisterOrderDetailRoute(r, pool)
+		RegisterOrderCountsRoute(authed, svc.Orders)
+		RegisterOrderListRoute(authed, svc.Orders)
+		RegisterOrderDetailRoute(authed, svc.Orders, files)
+		RegisterOrderPaymentRoute(authed, svc.Payments)
+		RegisterOrderReviewRoute(authed, svc.Orders)
+		RegisterOrderRefundRoutes(authed, svc.Refunds, files)
 
-		api.POST("/order/pay", func(c *gin.Context) {
-			type PayRequest struct {
-				OrderID int `json:"order_id"`
-			}
-			var req PayRequest
-			if err := c.ShouldBindJSON(&req); err != nil || req.OrderID == 0 {
-				c.JSON(400, gin.H{"error": "参数错误"})
-				return
-			}
-			session := sessions.Default(c)
-			username, ok := session.Get("user").(string)
-			if !ok || username == "" {
-				c.JSON(401, gin.H{"error": "未登录"})
-				return
-			}
-			// 查询用户id
-			var userID int
-			err := pool.QueryRow(context.Background(), "SELECT id FROM users WHERE username=$1", username).Scan(&userID)
-			if err != nil {
-				c.JSON(500, gin.H{"error": "用户不存在"})
-				return
-			}
-			// 校验订单归属和状态
-			var status string
-			err = pool.QueryRow(context.Background(), "SELECT status FROM orders WHERE id=$1 AND user_id=$2", req.OrderID, userID).Scan(&status)
-			if err != nil {
-				c.JSON(404, gin.H{"error": "订单不存在"})
-				return
-			}
-			if status != "pending" {
-				c.JSON(400, gin.H{"error": "订单状态不可支付"})
-				return
-			}
-			// 更新订单状态为已付款（如 toship）
-			_, err = pool.Exec(context.Background(), "UPDATE orders SET status='toship', updated_at=NOW() WHERE id=$1", req.OrderID)
-			if err != nil {
-				c.JSON(500, gin.H{"error": "支付失败"})
-				return
-			}
-			c.JSON(200, gin.H{"message": "支付成功"})
+		// 下单和支付支持 Idempotency-Key，重复提交时返回第一次的响应
+		keyed := authed.Group("", idempotent(svc.Idempotency, cfg.Idempotency.Retention, cfg.Idempotency.Lease))
+		RegisterOrderCreateRoute(keyed, svc.Orders)
+		RegisterOrderPayRoute(keyed, svc.Payments)
+
+		RegisterReviewImageRoute(authed, cfg, files)
+		RegisterReviewVoteRoute(authed, svc.Reviews)
+		RegisterRefundImageRoute(authed, cfg, files)
+
+		// 员工接口
+		staff := authed.Group("/admin", requireStaff())
+		RegisterReviewModerationRoutes(staff, svc.Reviews, files)
+		RegisterRefundReviewRoutes(staff, svc.Refunds, files)
+	}
+}
+
+// queryFloat 解析可选的数字查询参数，未传时返回 nil
+func queryFloat(c *gin.Context, key string) (*float64, error) {
+	v := c.Query(key)
+	if v == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("%s 不是合法数字", key)
+	}
+	return &f, nil
+}
+
+// queryInt 解析可选的整数查询参数，未传时返回 0
+func queryInt(c *gin.Context, key string) (int, error) {
+	v := c.Query(key)
+	if v == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(v)
+}
+
+// reviewsJSON 评价列表的响应格式
+func reviewsJSON(files storage.Storage, reviews []store.Review) []gin.H {
+	list := []gin.H{}
+	for _, r := range reviews {
+		list = append(list, gin.H{
+			"id": r.ID, "username": r.Username, "rating": r.Rating, "content": r.Content, "created_at": r.CreatedAt,
+			"model_id": r.ModelID, "images": storage.ResolveAll(files, r.Images), "helpful_count": r.HelpfulCount,
 		})
 	}
+	return list
 }
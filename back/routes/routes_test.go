@@ -0,0 +1,141 @@
+package routes_test
+
+import (
+	"back/config"
+	"back/payment"
+	"back/routes"
+	"back/service"
+	"back/storage"
+	"back/store"
+	"back/store/memory"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+)
+
+const testWebhookSecret = "test-webhook-secret"
+
+// testServer 基于内存存储和模拟支付渠道的完整路由，client 保存登录后的 session cookie
+type testServer struct {
+	t       *testing.T
+	router  *gin.Engine
+	db      *memory.DB
+	svc     *service.Services
+	mock    *payment.Mock
+	cookies []*http.Cookie
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db := memory.New()
+	db.AddProduct(store.Product{ID: 1, Title: "笔记本", Models: []store.Model{{ID: 10, Name: "i7", Price: 99.9, Stock: 5}}})
+	mock := payment.NewMock(payment.MockSuccess, 0, []byte(testWebhookSecret))
+	svc := service.New(db.Stores(), mock)
+	cfg := config.Default()
+	r := gin.New()
+	r.Use(sessions.Sessions("session", cookie.NewStore([]byte("test-session-secret"))))
+	routes.RegisterRoutes(r, svc, &cfg, storage.NewLocal(t.TempDir(), "/uploads/", []byte("test-url-secret"), time.Hour))
+	return &testServer{t: t, router: r, db: db, svc: svc, mock: mock}
+}
+
+// do 带上已保存的 cookie 发送 JSON 请求并保存响应设置的 cookie，headers 为可选的键值对
+func (s *testServer) do(method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	w := s.serve(method, path, body, s.cookies, headers...)
+	if cs := w.Result().Cookies(); len(cs) > 0 {
+		s.cookies = cs
+	}
+	return w
+}
+
+// serve 发送 JSON 请求，不读写 testServer 的状态，可在多个协程中并发调用
+func (s *testServer) serve(method, path, body string, cookies []*http.Cookie, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// login 注册并登录用户，并添加默认收货地址
+func (s *testServer) login(username string) {
+	s.t.Helper()
+	account := `{"username":"` + username + `","password":"secret"}`
+	s.expect(s.do("POST", "/api/register", account), 200)
+	s.expect(s.do("POST", "/api/login", account), 200)
+	s.expect(s.do("POST", "/api/user/addresses",
+		`{"recipient":"张三","phone":"13800138000","province":"广东省","city":"深圳市","district":"南山区","detail":"科技园1号"}`), 200)
+}
+
+func (s *testServer) expect(w *httptest.ResponseRecorder, code int) map[string]interface{} {
+	s.t.Helper()
+	if w.Code != code {
+		s.t.Fatalf("status = %d, want %d, body %s", w.Code, code, w.Body.String())
+	}
+	var body map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+	return body
+}
+
+func TestCheckoutFlow(t *testing.T) {
+	s := newTestServer(t)
+	s.expect(s.do("GET", "/api/cart", ""), 401)
+	s.login("alice")
+
+	s.expect(s.do("POST", "/api/cart", `{"product_id":1,"model_id":10,"qty":2}`), 200)
+	w := s.do("GET", "/api/cart", "")
+	var cart []map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &cart); err != nil || len(cart) != 1 || cart[0]["qty"] != 2.0 {
+		t.Fatalf("cart = %s", w.Body.String())
+	}
+
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"price mismatch", `{"items":[{"product_id":1,"model_id":10,"quantity":1,"price":1}]}`, 409},
+		{"total mismatch", `{"items":[{"product_id":1,"model_id":10,"quantity":1}],"total":1}`, 409},
+		{"stock shortage", `{"items":[{"product_id":1,"model_id":10,"quantity":6}]}`, 409},
+		{"unknown model", `{"items":[{"product_id":1,"model_id":11,"quantity":1}]}`, 400},
+		{"bad json", `{"items":`, 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.expect(s.do("POST", "/api/order/create", tt.body), tt.code)
+		})
+	}
+	if got := s.db.ModelStock(10); got != 5 {
+		t.Fatalf("stock after rejected orders = %d, want 5", got)
+	}
+
+	body := s.expect(s.do("POST", "/api/order/create", `{"items":[{"product_id":1,"model_id":10,"quantity":2,"price":99.9}],"total":199.8}`), 200)
+	if body["total"] != 199.8 || body["order_id"] != 1.0 {
+		t.Fatalf("create response = %v", body)
+	}
+	if got := s.db.ModelStock(10); got != 3 {
+		t.Fatalf("stock = %d, want 3", got)
+	}
+	detail := s.expect(s.do("GET", "/api/order/detail?id=1", ""), 200)
+	if detail["status"] != "pending" || detail["total_price"] != 199.8 {
+		t.Fatalf("detail = %v", detail)
+	}
+	counts := s.expect(s.do("GET", "/api/order/counts", ""), 200)
+	if counts["pending"] != 1.0 {
+		t.Fatalf("counts = %v", counts)
+	}
+}
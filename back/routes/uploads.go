@@ -0,0 +1,110 @@
+package routes
+
+import (
+	"back/config"
+	"back/media"
+	"back/storage"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 文件名中使用的内容哈希长度（十六进制字符数）
+const uploadHashLength = 32
+
+// multipart 表单中文件以外部分（边界、字段头）允许的额外字节数
+const multipartOverhead = 64 << 10
+
+// errNoFile 请求中没有对应字段的文件
+var errNoFile = errors.New("未选择文件")
+
+// savedImage 已保存的图片及其缩略图的对象 key
+type savedImage struct {
+	Key      string
+	ThumbKey string
+}
+
+// saveImage 读取表单字段 field 中的图片，经 media.Process 校验、重新编码后
+// 以 prefix+内容哈希 为 key 写入存储，同时写入缩略图。
+// key 不使用客户端提供的文件名，相同内容重复上传得到同一个 key
+func saveImage(c *gin.Context, field string, cfg config.UploadConfig, files storage.Storage, prefix string) (*savedImage, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, cfg.MaxSize+multipartOverhead)
+	header, err := c.FormFile(field)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, media.ErrTooLarge
+		}
+		return nil, errNoFile
+	}
+	if header.Size > cfg.MaxSize {
+		return nil, media.ErrTooLarge
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	img, err := media.Process(file, media.Options{MaxBytes: cfg.MaxSize, ThumbSize: cfg.ThumbSize})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := c.Request.Context()
+	saved := &savedImage{Key: prefix + img.Hash[:uploadHashLength] + img.Ext}
+	saved.ThumbKey = thumbKey(saved.Key)
+	if err := files.Put(ctx, saved.Key, img.Data, mime.TypeByExtension(img.Ext)); err != nil {
+		return nil, err
+	}
+	if err := files.Put(ctx, saved.ThumbKey, img.Thumb, "image/jpeg"); err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// avatarPrefix 头像 key 前缀，按用户 ID 分目录
+func avatarPrefix(userID int) string {
+	return "avatars/" + strconv.Itoa(userID) + "/"
+}
+
+// thumbKey 返回图片对应的缩略图 key
+func thumbKey(key string) string {
+	return strings.TrimSuffix(key, path.Ext(key)) + "_thumb.jpg"
+}
+
+// removeUpload 删除存储中的图片及其缩略图，外部地址不处理，删除失败只记日志
+func removeUpload(ctx context.Context, files storage.Storage, key string) {
+	if !storage.ValidKey(key) {
+		return
+	}
+	for _, k := range []string{key, thumbKey(key)} {
+		if err := files.Delete(ctx, k); err != nil {
+			log.Printf("删除上传文件 %s 失败: %v", k, err)
+		}
+	}
+}
+
+// uploadError 图片上传失败的响应
+func uploadError(c *gin.Context, err error, maxSize int64) {
+	switch {
+	case errors.Is(err, errNoFile):
+		c.JSON(400, gin.H{"error": "未选择文件"})
+	case errors.Is(err, media.ErrTooLarge):
+		c.JSON(413, gin.H{"error": fmt.Sprintf("文件过大，最大 %.1f MB", float64(maxSize)/(1<<20))})
+	case errors.Is(err, media.ErrUnsupportedType):
+		c.JSON(415, gin.H{"error": "仅支持 JPEG、PNG、GIF 图片"})
+	case errors.Is(err, media.ErrInvalidImage):
+		c.JSON(400, gin.H{"error": "图片无法解析"})
+	default:
+		log.Printf("保存上传文件失败: %v", err)
+		c.JSON(500, gin.H{"error": "保存失败"})
+	}
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"back/store"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// 每个用户最多保存的收货地址数
+const maxAddresses = 20
+
+// ErrAddressLimit 地址数量已达上限
+var ErrAddressLimit = fmt.Errorf("最多保存 %d 个收货地址", maxAddresses)
+
+// ErrNoAddress 下单时未指定收货地址且没有默认地址
+var ErrNoAddress = errors.New("请选择收货地址")
+
+// ErrAddressNotFound 下单时指定的收货地址不存在
+var ErrAddressNotFound = errors.New("收货地址不存在")
+
+// AddressFieldError 地址字段校验失败
+type AddressFieldError struct {
+	Field  string // 对应请求中的字段名
+	Reason string
+}
+
+func (e *AddressFieldError) Error() string {
+	return e.Field + ": " + e.Reason
+}
+
+var (
+	// 手机号，或带区号的固定电话（如 010-12345678）
+	phonePattern      = regexp.MustCompile(`^(1[3-9]\d{9}|0\d{2,3}-?\d{7,8})$`)
+	postalCodePattern = regexp.MustCompile(`^\d{6}$`)
+)
+
+// AddressService 收货地址簿
+type AddressService struct {
+	addresses store.AddressStore
+	users     store.UserStore
+}
+
+// AddressInput 新增或修改地址的参数
+type AddressInput struct {
+	Recipient  string
+	Phone      string
+	Province   string
+	City       string
+	District   string
+	Detail     string
+	PostalCode string
+	IsDefault  bool
+}
+
+// List 用户的全部地址，默认地址在前
+func (s *AddressService) List(ctx context.Context, userID int) ([]store.Address, error) {
+	return s.addresses.ListAddresses(ctx, userID)
+}
+
+// Create 新增地址，第一个地址自动成为默认地址
+func (s *AddressService) Create(ctx context.Context, userID int, in AddressInput) (*store.Address, error) {
+	ship, err := validateAddress(in)
+	if err != nil {
+		return nil, err
+	}
+	a := &store.Address{UserID: userID, ShippingAddress: ship, IsDefault: in.IsDefault}
+	err = s.addresses.CreateAddress(ctx, a, maxAddresses)
+	if errors.Is(err, store.ErrLimitExceeded) {
+		return nil, ErrAddressLimit
+	}
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Update 修改地址，不存在或不属于该用户时返回 store.ErrNotFound
+func (s *AddressService) Update(ctx context.Context, userID, id int, in AddressInput) (*store.Address, error) {
+	ship, err := validateAddress(in)
+	if err != nil {
+		return nil, err
+	}
+	a := &store.Address{ID: id, UserID: userID, ShippingAddress: ship, IsDefault: in.IsDefault}
+	if err := s.addresses.UpdateAddress(ctx, a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Delete 删除地址，删除默认地址时最近添加的其他地址成为默认
+func (s *AddressService) Delete(ctx context.Context, userID, id int) error {
+	return s.addresses.DeleteAddress(ctx, userID, id)
+}
+
+// SetDefault 设为默认地址
+func (s *AddressService) SetDefault(ctx context.Context, userID, id int) error {
+	return s.addresses.SetDefaultAddress(ctx, userID, id)
+}
+
+// shippingAddress 返回下单使用的地址：id 为 0 时使用默认地址
+func (s *AddressService) shippingAddress(ctx context.Context, userID, id int) (store.ShippingAddress, error) {
+	if id != 0 {
+		a, err := s.addresses.GetAddress(ctx, userID, id)
+		if errors.Is(err, store.ErrNotFound) {
+			return store.ShippingAddress{}, ErrAddressNotFound
+		}
+		if err != nil {
+			return store.ShippingAddress{}, err
+		}
+		return a.ShippingAddress, nil
+	}
+	list, err := s.addresses.ListAddresses(ctx, userID)
+	if err != nil {
+		return store.ShippingAddress{}, err
+	}
+	if len(list) == 0 {
+		if list, err = s.importLegacyAddress(ctx, userID); err != nil {
+			return store.ShippingAddress{}, err
+		}
+	}
+	if len(list) == 0 || !list[0].IsDefault {
+		return store.ShippingAddress{}, ErrNoAddress
+	}
+	return list[0].ShippingAddress, nil
+}
+
+// importLegacyAddress 地址簿为空时把旧版资料中的 users.address 写入地址簿作为默认地址，返回最新的地址列表。
+// 旧地址是一整段文本，放入 detail，收货人取昵称（没有时取用户名），其余字段留空，用户可在地址簿中补全
+func (s *AddressService) importLegacyAddress(ctx context.Context, userID int) ([]store.Address, error) {
+	u, err := s.users.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	detail := strings.TrimSpace(u.Address)
+	if detail == "" {
+		return nil, nil
+	}
+	recipient := u.Nickname
+	if recipient == "" {
+		recipient = u.Username
+	}
+	a := &store.Address{UserID: userID, ShippingAddress: store.ShippingAddress{
+		Recipient: truncateRunes(recipient, 32),
+		Detail:    truncateRunes(detail, 200),
+	}}
+	// limit 为 1：并发的结算请求只会导入一次，其余请求得到 ErrLimitExceeded 后直接读取导入的地址
+	if err := s.addresses.CreateAddress(ctx, a, 1); err != nil && !errors.Is(err, store.ErrLimitExceeded) {
+		return nil, err
+	}
+	return s.addresses.ListAddresses(ctx, userID)
+}
+
+// truncateRunes 截取前 n 个字符
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// validateAddress 去掉首尾空白后校验各字段
+func validateAddress(in AddressInput) (store.ShippingAddress, error) {
+	a := store.ShippingAddress{
+		Recipient:  strings.TrimSpace(in.Recipient),
+		Phone:      strings.TrimSpace(in.Phone),
+		Province:   strings.TrimSpace(in.Province),
+		City:       strings.TrimSpace(in.City),
+		District:   strings.TrimSpace(in.District),
+		Detail:     strings.TrimSpace(in.Detail),
+		PostalCode: strings.TrimSpace(in.PostalCode),
+	}
+	required := []struct {
+		field, value, name string
+		max                int
+	}{
+		{"recipient", a.Recipient, "收货人", 32},
+		{"province", a.Province, "省份", 32},
+		{"city", a.City, "城市", 32},
+		{"district", a.District, "区县", 32},
+		{"detail", a.Detail, "详细地址", 200},
+	}
+	for _, f := range required {
+		if f.value == "" {
+			return a, &AddressFieldError{Field: f.field, Reason: f.name + "不能为空"}
+		}
+		if utf8.RuneCountInString(f.value) > f.max {
+			return a, &AddressFieldError{Field: f.field, Reason: fmt.Sprintf("%s不能超过 %d 个字", f.name, f.max)}
+		}
+	}
+	if !phonePattern.MatchString(a.Phone) {
+		return a, &AddressFieldError{Field: "phone", Reason: "手机号格式错误"}
+	}
+	if a.PostalCode != "" && !postalCodePattern.MatchString(a.PostalCode) {
+		return a, &AddressFieldError{Field: "postal_code", Reason: "邮政编码应为 6 位数字"}
+	}
+	return a, nil
+}
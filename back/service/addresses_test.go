@@ -0,0 +1,62 @@
+package service_test
+
+import (
+	"back/service"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestAddressLimitConcurrent(t *testing.T) {
+	svc, _ := newTestServices(t)
+	ctx := context.Background()
+	u, err := svc.Users.Register(ctx, service.RegisterInput{Username: "bob", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 30
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = svc.Addresses.Create(ctx, u.ID, service.AddressInput{
+				Recipient: "李四", Phone: "13900139000", Province: "北京市", City: "北京市", District: "海淀区", Detail: "中关村1号",
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	created := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case !errors.Is(err, service.ErrAddressLimit):
+			t.Fatalf("Create error = %v", err)
+		}
+	}
+	if created != 20 {
+		t.Fatalf("created %d addresses, want 20", created)
+	}
+	list, err := svc.Addresses.List(ctx, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults := 0
+	for _, a := range list {
+		if a.IsDefault {
+			defaults++
+		}
+	}
+	if len(list) != 20 || defaults != 1 {
+		t.Fatalf("%d addresses with %d defaults, want 20 with 1", len(list), defaults)
+	}
+	// 其他用户不受影响
+	if list, _ := svc.Addresses.List(ctx, 1); len(list) != 1 {
+		t.Fatalf("alice has %d addresses, want 1", len(list))
+	}
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"back/store"
+	"context"
+)
+
+// CartService 购物车
+type CartService struct {
+	carts store.CartStore
+}
+
+// List 用户购物车
+func (s *CartService) List(ctx context.Context, userID int) ([]store.CartItem, error) {
+	return s.carts.ListCart(ctx, userID)
+}
+
+// Add 加入购物车，已有同型号时累加数量并返回 true
+func (s *CartService) Add(ctx context.Context, userID, productID, modelID, qty int) (bool, error) {
+	if productID == 0 || modelID == 0 || qty < 1 {
+		return false, ErrInvalidInput
+	}
+	return s.carts.AddToCart(ctx, userID, productID, modelID, qty)
+}
+
+// UpdateQuantity 修改数量，购物车项不属于该用户时返回 store.ErrNotFound
+func (s *CartService) UpdateQuantity(ctx context.Context, userID, cartID, qty int) error {
+	if cartID == 0 || qty < 1 {
+		return ErrInvalidInput
+	}
+	return s.carts.UpdateCartQuantity(ctx, userID, cartID, qty)
+}
+
+// Remove 移除购物车项
+func (s *CartService) Remove(ctx context.Context, userID, cartID int) error {
+	if cartID == 0 {
+		return ErrInvalidInput
+	}
+	return s.carts.RemoveFromCart(ctx, userID, cartID)
+}
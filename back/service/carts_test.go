@@ -0,0 +1,130 @@
+package service_test
+
+import (
+	"back/service"
+	"back/store"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCartAdd(t *testing.T) {
+	tests := []struct {
+		name       string
+		adds       [][3]int // productID, modelID, qty
+		wantMerged []bool
+		wantErr    error
+		wantQty    map[int]int // model id -> 购物车中的数量
+	}{
+		{
+			name:       "new item",
+			adds:       [][3]int{{1, 10, 2}},
+			wantMerged: []bool{false},
+			wantQty:    map[int]int{10: 2},
+		},
+		{
+			name:       "same model merges quantity",
+			adds:       [][3]int{{1, 10, 2}, {2, 20, 1}, {1, 10, 3}},
+			wantMerged: []bool{false, false, true},
+			wantQty:    map[int]int{10: 5, 20: 1},
+		},
+		{
+			name:    "zero quantity",
+			adds:    [][3]int{{1, 10, 0}},
+			wantErr: service.ErrInvalidInput,
+		},
+		{
+			name:    "missing model",
+			adds:    [][3]int{{1, 0, 1}},
+			wantErr: service.ErrInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newTestServices(t)
+			ctx := context.Background()
+			for i, a := range tt.adds {
+				merged, err := svc.Carts.Add(ctx, 1, a[0], a[1], a[2])
+				if tt.wantErr != nil {
+					if !errors.Is(err, tt.wantErr) {
+						t.Fatalf("Add error = %v, want %v", err, tt.wantErr)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("Add: %v", err)
+				}
+				if merged != tt.wantMerged[i] {
+					t.Errorf("add #%d merged = %v, want %v", i, merged, tt.wantMerged[i])
+				}
+			}
+			items, err := svc.Carts.List(ctx, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(items) != len(tt.wantQty) {
+				t.Fatalf("cart has %d items, want %d", len(items), len(tt.wantQty))
+			}
+			for _, it := range items {
+				if it.Quantity != tt.wantQty[it.ModelID] {
+					t.Errorf("model %d quantity = %d, want %d", it.ModelID, it.Quantity, tt.wantQty[it.ModelID])
+				}
+			}
+		})
+	}
+}
+
+func TestCartUpdateAndRemove(t *testing.T) {
+	svc, _ := newTestServices(t)
+	ctx := context.Background()
+	if _, err := svc.Carts.Add(ctx, 1, 1, 10, 1); err != nil {
+		t.Fatal(err)
+	}
+	items, err := svc.Carts.List(ctx, 1)
+	if err != nil || len(items) != 1 {
+		t.Fatalf("List = %v, %v", items, err)
+	}
+	cartID := items[0].ID
+	if items[0].Title != "笔记本" || items[0].ModelName != "i7" || items[0].Price != 99.9 {
+		t.Errorf("cart item = %+v", items[0])
+	}
+
+	tests := []struct {
+		name    string
+		userID  int
+		cartID  int
+		qty     int
+		wantErr error
+	}{
+		{"update", 1, cartID, 4, nil},
+		{"zero quantity", 1, cartID, 0, service.ErrInvalidInput},
+		{"other user", 2, cartID, 1, store.ErrNotFound},
+		{"unknown item", 1, 99, 1, store.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := svc.Carts.UpdateQuantity(ctx, tt.userID, tt.cartID, tt.qty)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateQuantity error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+	items, _ = svc.Carts.List(ctx, 1)
+	if items[0].Quantity != 4 {
+		t.Errorf("quantity = %d, want 4", items[0].Quantity)
+	}
+
+	// 删除其他用户的购物车项不生效
+	if err := svc.Carts.Remove(ctx, 2, cartID); err != nil {
+		t.Fatal(err)
+	}
+	if items, _ = svc.Carts.List(ctx, 1); len(items) != 1 {
+		t.Fatalf("cart emptied by another user")
+	}
+	if err := svc.Carts.Remove(ctx, 1, cartID); err != nil {
+		t.Fatal(err)
+	}
+	if items, _ = svc.Carts.List(ctx, 1); len(items) != 0 {
+		t.Fatalf("cart has %d items after remove", len(items))
+	}
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"back/store"
+	"context"
+)
+
+// CategoryService 商品分类
+type CategoryService struct {
+	categories store.CategoryStore
+}
+
+// CategoryNode 分类树节点，ProductCount 含全部子孙分类下的商品
+type CategoryNode struct {
+	store.Category
+	Children []*CategoryNode
+}
+
+// Tree 返回分类树，同级按 sort_order、id 排序
+func (s *CategoryService) Tree(ctx context.Context) ([]*CategoryNode, error) {
+	list, err := s.categories.ListCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return buildCategoryTree(list), nil
+}
+
+// buildCategoryTree 由按 sort_order 排好序的分类列表构建树，
+// 从一级分类出发，父分类不存在或成环的节点不会出现在树中
+func buildCategoryTree(list []store.Category) []*CategoryNode {
+	children := map[int][]store.Category{}
+	for _, c := range list {
+		children[c.ParentID] = append(children[c.ParentID], c)
+	}
+	visited := map[int]bool{}
+	var build func(parentID int) []*CategoryNode
+	build = func(parentID int) []*CategoryNode {
+		nodes := []*CategoryNode{}
+		for _, c := range children[parentID] {
+			if visited[c.ID] {
+				continue
+			}
+			visited[c.ID] = true
+			node := &CategoryNode{Category: c, Children: build(c.ID)}
+			for _, child := range node.Children {
+				node.ProductCount += child.ProductCount
+			}
+			nodes = append(nodes, node)
+		}
+		return nodes
+	}
+	return build(0)
+}
+
+// subtreeIDs 返回 id 及其全部子孙分类的 id，分类不存在时返回 store.ErrNotFound
+func subtreeIDs(list []store.Category, id int) ([]int, error) {
+	found := false
+	children := map[int][]int{}
+	for _, c := range list {
+		children[c.ParentID] = append(children[c.ParentID], c.ID)
+		if c.ID == id {
+			found = true
+		}
+	}
+	if !found {
+		return nil, store.ErrNotFound
+	}
+	ids := []int{id}
+	seen := map[int]bool{id: true}
+	for i := 0; i < len(ids); i++ {
+		for _, child := range children[ids[i]] {
+			if !seen[child] {
+				seen[child] = true
+				ids = append(ids, child)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// CategoryFilter 按分类 id 或 slug/名称筛选，两者都为空表示不筛选
+type CategoryFilter struct {
+	ID   int
+	Slug string // 也接受分类名称，兼容旧的 ?category=手机
+}
+
+// resolveCategoryFilter 把分类筛选条件展开为包含子孙分类的 id 列表，不筛选时返回 nil
+func resolveCategoryFilter(ctx context.Context, categories store.CategoryStore, f CategoryFilter) ([]int, error) {
+	if f.ID == 0 && f.Slug == "" {
+		return nil, nil
+	}
+	list, err := categories.ListCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id := f.ID
+	if id == 0 {
+		for _, c := range list {
+			if c.Slug == f.Slug || c.Name == f.Slug {
+				id = c.ID
+				break
+			}
+		}
+	}
+	return subtreeIDs(list, id)
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"back/store"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		sort store.ProductSort
+		c    store.ProductCursor
+	}{
+		{store.SortNewest, store.ProductCursor{Key: 42, ID: 42}},
+		{store.SortPriceAsc, store.ProductCursor{Key: 0.1, ID: 7}},
+		{store.SortPriceDesc, store.ProductCursor{Key: 12345.67, ID: 1}},
+		{store.SortSales, store.ProductCursor{Key: 0, ID: 3}},
+		{store.SortRating, store.ProductCursor{Key: 4.333333333333333, ID: 9}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.sort), func(t *testing.T) {
+			cursor := encodeCursor(tt.sort, &tt.c)
+			got, err := decodeCursor(cursor, tt.sort)
+			if err != nil {
+				t.Fatalf("decodeCursor(%q) error = %v", cursor, err)
+			}
+			if *got != tt.c {
+				t.Errorf("decodeCursor = %+v, want %+v", *got, tt.c)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	enc := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"cursor from another sort", encodeCursor(store.SortPriceDesc, &store.ProductCursor{Key: 9.9, ID: 1})},
+		{"malformed base64", "not base64!"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte("price_asc:9.9:10"))},
+		{"standard alphabet", "cHJpY2VfYXNjOjk/OTox"},
+		{"too few parts", enc("price_asc:9.9")},
+		{"too many parts", enc("price_asc:9.9:1:2")},
+		{"key not a number", enc("price_asc:abc:1")},
+		{"key NaN", enc("price_asc:NaN:1")},
+		{"key infinite", enc("price_asc:+Inf:1")},
+		{"id not a number", enc("price_asc:9.9:x")},
+		{"empty after decoding", enc("")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if c, err := decodeCursor(tt.cursor, store.SortPriceAsc); !errors.Is(err, ErrInvalidInput) {
+				t.Fatalf("decodeCursor(%q) = %+v, %v, want ErrInvalidInput", tt.cursor, c, err)
+			}
+		})
+	}
+}
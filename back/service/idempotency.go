@@ -0,0 +1,53 @@
+package service
+
+import (
+	"back/store"
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrIdempotencyMismatch 幂等键已用于内容不同的请求
+var ErrIdempotencyMismatch = errors.New("Idempotency-Key 已用于其他请求")
+
+// ErrIdempotencyInProgress 同一幂等键的请求仍在处理中
+var ErrIdempotencyInProgress = errors.New("相同 Idempotency-Key 的请求正在处理中")
+
+// IdempotencyService 幂等键：保留期内同一用户用同一 key 重复提交相同请求时返回第一次的响应
+type IdempotencyService struct {
+	keys store.IdempotencyStore
+}
+
+// Begin 开始处理带幂等键的请求，fingerprint 为请求内容的摘要。
+// 返回 nil 表示这是首次请求，处理完后需调用 Complete 或 Release；
+// 已有完成的记录时返回该记录供重放。key 用于其他请求时返回 ErrIdempotencyMismatch，
+// 上一次请求尚未完成时返回 ErrIdempotencyInProgress；处理中超过 lease 的记录视为已中断，本次请求重新占用
+func (s *IdempotencyService) Begin(ctx context.Context, userID int, key, fingerprint string, retention, lease time.Duration) (*store.IdempotencyRecord, error) {
+	now := time.Now()
+	r, err := s.keys.ReserveIdempotencyKey(ctx, userID, key, fingerprint, now.Add(-retention), now.Add(-lease))
+	if err != nil || r == nil {
+		return nil, err
+	}
+	if r.Fingerprint != fingerprint {
+		return nil, ErrIdempotencyMismatch
+	}
+	if !r.Completed {
+		return nil, ErrIdempotencyInProgress
+	}
+	return r, nil
+}
+
+// Complete 保存首次请求的响应
+func (s *IdempotencyService) Complete(ctx context.Context, userID int, key string, statusCode int, response []byte) error {
+	return s.keys.CompleteIdempotencyKey(ctx, userID, key, statusCode, response)
+}
+
+// Release 放弃首次请求的记录（如服务端出错），客户端可以用同一 key 重试
+func (s *IdempotencyService) Release(ctx context.Context, userID int, key string) error {
+	return s.keys.ReleaseIdempotencyKey(ctx, userID, key)
+}
+
+// PurgeExpired 删除超过保留时间的幂等键，返回删除的数量
+func (s *IdempotencyService) PurgeExpired(ctx context.Context, retention time.Duration, limit int) (int, error) {
+	return s.keys.DeleteIdempotencyKeysBefore(ctx, time.Now().Add(-retention), limit)
+}
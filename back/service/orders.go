@@ -0,0 +1,264 @@
+package service
+
+import (
+	"back/orderstate"
+	"back/store"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+// 单条明细的数量上限
+const maxItemQuantity = 999
+
+// 订单总价上限（单位：分），即 total_price numeric(10,2) 能存下的最大值
+const maxOrderTotalCents = 99_999_999_99
+
+// ErrModelNotFound 型号不存在或不属于对应商品
+var ErrModelNotFound = errors.New("商品型号不存在")
+
+// ErrNotPayable 订单当前状态不可支付
+var ErrNotPayable = errors.New("订单状态不可支付")
+
+// PriceMismatch 客户端单价与服务端不一致的明细
+type PriceMismatch struct {
+	ProductID   int
+	ModelID     int
+	ClientPrice float64
+	Price       float64
+}
+
+// PriceMismatchError 有明细单价与服务端不一致
+type PriceMismatchError struct {
+	Items []PriceMismatch
+}
+
+func (e *PriceMismatchError) Error() string {
+	return fmt.Sprintf("%d 个商品价格已变动", len(e.Items))
+}
+
+// TotalMismatchError 客户端总价与服务端计算结果不一致
+type TotalMismatchError struct {
+	Total float64
+}
+
+func (e *TotalMismatchError) Error() string {
+	return fmt.Sprintf("订单金额不一致，应为 %.2f", e.Total)
+}
+
+// StockShortage 库存不足的型号
+type StockShortage struct {
+	ProductID int
+	ModelID   int
+	Quantity  int
+	Stock     int
+}
+
+// StockShortageError 有型号库存不足
+type StockShortageError struct {
+	Items []StockShortage
+}
+
+func (e *StockShortageError) Error() string {
+	return fmt.Sprintf("%d 个商品库存不足", len(e.Items))
+}
+
+// OrderService 订单业务：下单计价、库存校验、状态流转
+type OrderService struct {
+	orders    store.OrderStore
+	addresses *AddressService
+	payments  *PaymentService
+}
+
+// OrderItemInput 下单明细（客户端提交），Price 仅用于核对
+type OrderItemInput struct {
+	ProductID int
+	ModelID   int
+	Quantity  int
+	Price     *float64
+}
+
+// CreateOrderInput 下单参数，Total 仅用于核对
+type CreateOrderInput struct {
+	Items     []OrderItemInput
+	AddressID int // 地址簿中的地址 ID，为 0 时使用默认地址
+	Total     *float64
+}
+
+// OrderDetail 订单详情及状态流转记录
+type OrderDetail struct {
+	store.Order
+	History []orderstate.HistoryEntry
+}
+
+// Counts 各状态订单数量，所有状态都会出现在结果中
+func (s *OrderService) Counts(ctx context.Context, userID int) (map[orderstate.Status]int, error) {
+	counts, err := s.orders.CountOrdersByStatus(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, st := range orderstate.Orders.Statuses() {
+		if _, ok := counts[st]; !ok {
+			counts[st] = 0
+		}
+	}
+	return counts, nil
+}
+
+// List 订单列表，status 为空时返回全部
+func (s *OrderService) List(ctx context.Context, userID int, status string) ([]store.Order, error) {
+	if status != "" && !orderstate.Orders.Valid(orderstate.Status(status)) {
+		return nil, ErrInvalidInput
+	}
+	return s.orders.ListOrders(ctx, userID, orderstate.Status(status))
+}
+
+// Detail 订单详情，订单不存在或不属于该用户时返回 store.ErrNotFound
+func (s *OrderService) Detail(ctx context.Context, userID, orderID int) (*OrderDetail, error) {
+	o, err := s.orders.GetOrder(ctx, userID, orderID)
+	if err != nil {
+		return nil, err
+	}
+	history, err := s.orders.OrderHistory(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	return &OrderDetail{Order: *o, History: history}, nil
+}
+
+// Create 下单：单价和总价以服务端型号价格为准，客户端金额不一致时拒绝；
+// 在同一事务内校验并扣减库存，返回订单 ID 和服务端计算的总价。
+// 收货地址在下单时复制到订单中，之后修改地址簿不影响订单
+func (s *OrderService) Create(ctx context.Context, userID int, actor string, in CreateOrderInput) (int, float64, error) {
+	if len(in.Items) == 0 {
+		return 0, 0, ErrInvalidInput
+	}
+	modelIDs := make([]int, 0, len(in.Items))
+	for _, item := range in.Items {
+		if item.ProductID == 0 || item.ModelID == 0 || item.Quantity < 1 || item.Quantity > maxItemQuantity {
+			return 0, 0, ErrInvalidInput
+		}
+		modelIDs = append(modelIDs, item.ModelID)
+	}
+	ship, err := s.addresses.shippingAddress(ctx, userID, in.AddressID)
+	if err != nil {
+		return 0, 0, err
+	}
+	var total int64
+	orderID, err := s.orders.CreateOrder(ctx, userID, ship, actor, modelIDs, func(models map[int]store.Model) (*store.NewOrder, error) {
+		items, sum, err := priceItems(in.Items, models)
+		if err != nil {
+			return nil, err
+		}
+		if in.Total != nil && toCents(*in.Total) != sum {
+			return nil, &TotalMismatchError{Total: fromCents(sum)}
+		}
+		if err := checkStock(items, models); err != nil {
+			return nil, err
+		}
+		total = sum
+		return &store.NewOrder{Total: fromCents(sum), Items: items}, nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return orderID, fromCents(total), nil
+}
+
+// ExpirePending 取消创建时间超过 timeout 仍未支付的订单并归还库存，返回取消的数量。
+// 订单还有待支付记录时先向支付渠道查询，已付款的订单会流转为待发货而不是被取消；
+// 查询失败的订单本轮跳过。已被支付或已被其他实例取消的订单会被跳过。
+// 取消后才到达的支付成功会被标记为需要退回，由 PaymentService.RefundUnmatched 原路退款
+func (s *OrderService) ExpirePending(ctx context.Context, timeout time.Duration, limit int, reason string) (int, error) {
+	ids, err := s.orders.ListPendingOrdersBefore(ctx, time.Now().Add(-timeout), limit)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, id := range ids {
+		if err := s.payments.refreshPending(ctx, id); err != nil {
+			if ctx.Err() != nil {
+				return n, ctx.Err()
+			}
+			log.Printf("查询订单 %d 的支付状态失败，暂不取消: %v", id, err)
+			continue
+		}
+		err := s.orders.CancelOrder(ctx, id, orderstate.ActorSystem, reason)
+		var transErr *orderstate.TransitionError
+		if errors.As(err, &transErr) || errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// priceItems 以型号价格重新计算每项单价和总价（单位：分），客户端单价不一致时返回 *PriceMismatchError，
+// 总价超出 maxOrderTotalCents 时返回 ErrInvalidInput。调用方需先将数量限制在 maxItemQuantity 以内，
+// 单价同为 numeric(10,2)，因此每项的乘积不会溢出 int64
+func priceItems(items []OrderItemInput, models map[int]store.Model) ([]store.OrderItem, int64, error) {
+	priced := make([]store.OrderItem, 0, len(items))
+	var mismatches []PriceMismatch
+	var total int64
+	for _, item := range items {
+		m, ok := models[item.ModelID]
+		if !ok || m.ProductID != item.ProductID {
+			return nil, 0, ErrModelNotFound
+		}
+		unit := toCents(m.Price)
+		if item.Price != nil && toCents(*item.Price) != unit {
+			mismatches = append(mismatches, PriceMismatch{
+				ProductID:   item.ProductID,
+				ModelID:     item.ModelID,
+				ClientPrice: *item.Price,
+				Price:       fromCents(unit),
+			})
+		}
+		priced = append(priced, store.OrderItem{
+			ProductID: item.ProductID,
+			ModelID:   item.ModelID,
+			Quantity:  item.Quantity,
+			Price:     fromCents(unit),
+		})
+		total += unit * int64(item.Quantity)
+		if total > maxOrderTotalCents {
+			return nil, 0, ErrInvalidInput
+		}
+	}
+	if len(mismatches) > 0 {
+		return nil, 0, &PriceMismatchError{Items: mismatches}
+	}
+	return priced, total, nil
+}
+
+// checkStock 校验库存，同一型号出现多次时合并数量；合计超出库存列（int4）的范围时返回 ErrInvalidInput
+func checkStock(items []store.OrderItem, models map[int]store.Model) error {
+	need := map[int]int{}
+	var order []int
+	for _, item := range items {
+		if _, ok := need[item.ModelID]; !ok {
+			order = append(order, item.ModelID)
+		}
+		if item.Quantity > math.MaxInt32-need[item.ModelID] {
+			return ErrInvalidInput
+		}
+		need[item.ModelID] += item.Quantity
+	}
+	var shortages []StockShortage
+	for _, id := range order {
+		m := models[id]
+		if need[id] > m.Stock {
+			shortages = append(shortages, StockShortage{ProductID: m.ProductID, ModelID: id, Quantity: need[id], Stock: m.Stock})
+		}
+	}
+	if len(shortages) > 0 {
+		return &StockShortageError{Items: shortages}
+	}
+	return nil
+}
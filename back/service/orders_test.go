@@ -0,0 +1,225 @@
+package service_test
+
+import (
+	"back/orderstate"
+	"back/payment"
+	"back/service"
+	"back/store"
+	"back/store/memory"
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+// newTestServices 基于内存存储创建服务，预置两个商品和一个带默认地址的用户（ID 为 1）
+func newTestServices(t *testing.T) (*service.Services, *memory.DB) {
+	t.Helper()
+	svc, db, _ := newTestServicesWithMock(t)
+	return svc, db
+}
+
+// newTestServicesWithMock 同 newTestServices，并返回使用的模拟支付渠道
+func newTestServicesWithMock(t *testing.T) (*service.Services, *memory.DB, *payment.Mock) {
+	t.Helper()
+	db := memory.New()
+	db.AddProduct(store.Product{ID: 1, Title: "笔记本", Models: []store.Model{{ID: 10, Name: "i7", Price: 99.9, Stock: 5}}})
+	db.AddProduct(store.Product{ID: 2, Title: "鼠标", Models: []store.Model{{ID: 20, Name: "无线", Price: 0.1, Stock: 100}}})
+	mock := payment.NewMock(payment.MockSuccess, 0, []byte("test-secret"))
+	svc := service.New(db.Stores(), mock)
+	ctx := context.Background()
+	u, err := svc.Users.Register(ctx, service.RegisterInput{Username: "alice", Password: "secret"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	_, err = svc.Addresses.Create(ctx, u.ID, service.AddressInput{
+		Recipient: "张三", Phone: "13800138000", Province: "广东省", City: "深圳市", District: "南山区", Detail: "科技园1号",
+	})
+	if err != nil {
+		t.Fatalf("Create address: %v", err)
+	}
+	return svc, db, mock
+}
+
+func price(v float64) *float64 { return &v }
+
+func TestOrderCreate(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        service.CreateOrderInput
+		wantTotal float64
+		wantErr   func(error) bool
+		wantStock map[int]int // 下单后各型号库存
+	}{
+		{
+			name:      "success",
+			in:        service.CreateOrderInput{Items: []service.OrderItemInput{{ProductID: 1, ModelID: 10, Quantity: 2, Price: price(99.9)}, {ProductID: 2, ModelID: 20, Quantity: 3}}, Total: price(200.1)},
+			wantTotal: 200.1,
+			wantStock: map[int]int{10: 3, 20: 97},
+		},
+		{
+			name: "price mismatch",
+			in:   service.CreateOrderInput{Items: []service.OrderItemInput{{ProductID: 1, ModelID: 10, Quantity: 1, Price: price(89.9)}}},
+			wantErr: func(err error) bool {
+				var e *service.PriceMismatchError
+				return errors.As(err, &e) && len(e.Items) == 1 && e.Items[0].Price == 99.9 && e.Items[0].ClientPrice == 89.9
+			},
+			wantStock: map[int]int{10: 5},
+		},
+		{
+			name: "total mismatch",
+			in:   service.CreateOrderInput{Items: []service.OrderItemInput{{ProductID: 2, ModelID: 20, Quantity: 3}}, Total: price(0.31)},
+			wantErr: func(err error) bool {
+				var e *service.TotalMismatchError
+				return errors.As(err, &e) && e.Total == 0.3
+			},
+			wantStock: map[int]int{20: 100},
+		},
+		{
+			name: "stock shortage counts repeated models together",
+			in:   service.CreateOrderInput{Items: []service.OrderItemInput{{ProductID: 1, ModelID: 10, Quantity: 3}, {ProductID: 1, ModelID: 10, Quantity: 3}}},
+			wantErr: func(err error) bool {
+				var e *service.StockShortageError
+				return errors.As(err, &e) && len(e.Items) == 1 && e.Items[0].Quantity == 6 && e.Items[0].Stock == 5
+			},
+			wantStock: map[int]int{10: 5},
+		},
+		{
+			name:      "model of another product",
+			in:        service.CreateOrderInput{Items: []service.OrderItemInput{{ProductID: 2, ModelID: 10, Quantity: 1}}},
+			wantErr:   func(err error) bool { return errors.Is(err, service.ErrModelNotFound) },
+			wantStock: map[int]int{10: 5},
+		},
+		{
+			name:    "empty items",
+			in:      service.CreateOrderInput{},
+			wantErr: func(err error) bool { return errors.Is(err, service.ErrInvalidInput) },
+		},
+		{
+			name:      "quantity over cap",
+			in:        service.CreateOrderInput{Items: []service.OrderItemInput{{ProductID: 2, ModelID: 20, Quantity: 1000}}},
+			wantErr:   func(err error) bool { return errors.Is(err, service.ErrInvalidInput) },
+			wantStock: map[int]int{20: 100},
+		},
+		{
+			name:      "quantity that would wrap",
+			in:        service.CreateOrderInput{Items: []service.OrderItemInput{{ProductID: 1, ModelID: 10, Quantity: math.MaxInt}, {ProductID: 1, ModelID: 10, Quantity: math.MaxInt}}},
+			wantErr:   func(err error) bool { return errors.Is(err, service.ErrInvalidInput) },
+			wantStock: map[int]int{10: 5},
+		},
+		{
+			name:    "zero quantity",
+			in:      service.CreateOrderInput{Items: []service.OrderItemInput{{ProductID: 1, ModelID: 10}}},
+			wantErr: func(err error) bool { return errors.Is(err, service.ErrInvalidInput) },
+		},
+		{
+			name:    "unknown address",
+			in:      service.CreateOrderInput{Items: []service.OrderItemInput{{ProductID: 1, ModelID: 10, Quantity: 1}}, AddressID: 99},
+			wantErr: func(err error) bool { return errors.Is(err, service.ErrAddressNotFound) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, db := newTestServices(t)
+			ctx := context.Background()
+			id, total, err := svc.Orders.Create(ctx, 1, orderstate.UserActor("alice"), tt.in)
+			if tt.wantErr != nil {
+				if err == nil || !tt.wantErr(err) {
+					t.Fatalf("Create error = %v", err)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("Create: %v", err)
+				}
+				if total != tt.wantTotal {
+					t.Errorf("total = %v, want %v", total, tt.wantTotal)
+				}
+				o, err := svc.Orders.Detail(ctx, 1, id)
+				if err != nil {
+					t.Fatalf("Detail: %v", err)
+				}
+				if o.Status != orderstate.Pending || o.TotalPrice != tt.wantTotal || len(o.Items) != len(tt.in.Items) {
+					t.Errorf("order = %+v", o.Order)
+				}
+				if o.Shipping.Recipient != "张三" {
+					t.Errorf("shipping = %+v, want default address", o.Shipping)
+				}
+			}
+			for modelID, want := range tt.wantStock {
+				if got := db.ModelStock(modelID); got != want {
+					t.Errorf("stock of model %d = %d, want %d", modelID, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestOrderCreateWithoutAddress(t *testing.T) {
+	svc, _ := newTestServices(t)
+	ctx := context.Background()
+	u, err := svc.Users.Register(ctx, service.RegisterInput{Username: "bob", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = svc.Orders.Create(ctx, u.ID, orderstate.UserActor("bob"), service.CreateOrderInput{
+		Items: []service.OrderItemInput{{ProductID: 1, ModelID: 10, Quantity: 1}},
+	})
+	if !errors.Is(err, service.ErrNoAddress) {
+		t.Fatalf("Create error = %v, want ErrNoAddress", err)
+	}
+}
+
+func TestOrderCreateTotalOutOfRange(t *testing.T) {
+	svc, db := newTestServices(t)
+	db.AddProduct(store.Product{ID: 3, Title: "整机", Models: []store.Model{{ID: 30, Name: "旗舰", Price: 99999999.99, Stock: 10}}})
+	ctx := context.Background()
+	// 单价本身能存下，两件的总价超出 numeric(10,2)
+	_, _, err := svc.Orders.Create(ctx, 1, orderstate.UserActor("alice"), service.CreateOrderInput{
+		Items: []service.OrderItemInput{{ProductID: 3, ModelID: 30, Quantity: 2}},
+	})
+	if !errors.Is(err, service.ErrInvalidInput) {
+		t.Fatalf("Create error = %v, want ErrInvalidInput", err)
+	}
+	if got := db.ModelStock(30); got != 10 {
+		t.Fatalf("stock = %d, want 10", got)
+	}
+	if _, total, err := svc.Orders.Create(ctx, 1, orderstate.UserActor("alice"), service.CreateOrderInput{
+		Items: []service.OrderItemInput{{ProductID: 3, ModelID: 30, Quantity: 1}},
+	}); err != nil || total != 99999999.99 {
+		t.Fatalf("Create = %v, %v", total, err)
+	}
+}
+
+func TestOrderCreateImportsLegacyAddress(t *testing.T) {
+	svc, _ := newTestServices(t)
+	ctx := context.Background()
+	u, err := svc.Users.Register(ctx, service.RegisterInput{Username: "bob", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Users.UpdateAddress(ctx, u.ID, "  北京市海淀区中关村1号  "); err != nil {
+		t.Fatal(err)
+	}
+	in := service.CreateOrderInput{Items: []service.OrderItemInput{{ProductID: 1, ModelID: 10, Quantity: 1}}}
+	for i := 0; i < 2; i++ {
+		id, _, err := svc.Orders.Create(ctx, u.ID, orderstate.UserActor("bob"), in)
+		if err != nil {
+			t.Fatalf("Create with legacy address: %v", err)
+		}
+		o, err := svc.Orders.Detail(ctx, u.ID, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if o.Shipping.Detail != "北京市海淀区中关村1号" || o.Shipping.Recipient != "bob" {
+			t.Errorf("shipping = %+v", o.Shipping)
+		}
+	}
+	// 只导入一次，之后使用地址簿中的默认地址
+	list, err := svc.Addresses.List(ctx, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || !list[0].IsDefault {
+		t.Fatalf("address book = %+v, want one default address", list)
+	}
+}
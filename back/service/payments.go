@@ -0,0 +1,184 @@
+package service
+
+import (
+	"back/orderstate"
+	"back/payment"
+	"back/store"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+)
+
+// PaymentService 订单支付：向支付渠道发起交易并根据交易结果推进订单状态
+type PaymentService struct {
+	payments store.PaymentStore
+	orders   store.OrderStore
+	provider payment.Provider
+}
+
+// Pay 为待付款订单发起支付：已有待支付记录时沿用，否则向支付渠道创建交易。
+// 订单是否已付款以渠道的交易结果为准，返回前会向渠道查询一次状态
+func (s *PaymentService) Pay(ctx context.Context, userID, orderID int) (*store.Payment, error) {
+	o, err := s.orders.GetOrder(ctx, userID, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if o.Status != orderstate.Pending {
+		return nil, ErrNotPayable
+	}
+	p, err := s.payments.LatestPayment(ctx, orderID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+	if p == nil || p.Status == payment.StatusFailed {
+		if p, err = s.createPayment(ctx, o); err != nil {
+			return nil, err
+		}
+	}
+	return s.sync(ctx, p)
+}
+
+// Status 订单最近一次支付的状态，待支付时先向渠道查询。
+// 订单不属于该用户或还没有发起过支付时返回 store.ErrNotFound
+func (s *PaymentService) Status(ctx context.Context, userID, orderID int) (*store.Payment, error) {
+	if _, err := s.orders.GetOrder(ctx, userID, orderID); err != nil {
+		return nil, err
+	}
+	p, err := s.payments.LatestPayment(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	return s.sync(ctx, p)
+}
+
+// HandleWebhook 校验支付渠道的异步通知并结算对应支付，signature 为请求头 payment.SignatureHeader 的值。
+// 重复的通知（同一通知 ID）不再处理，返回 duplicate 为 true；不表示最终结果的通知直接忽略。
+// 签名无效返回 payment.ErrInvalidSignature，交易不存在返回 store.ErrNotFound，渠道收到非 2xx 响应后会重试
+func (s *PaymentService) HandleWebhook(ctx context.Context, signature string, body []byte) (duplicate bool, err error) {
+	e, err := s.provider.ParseWebhook(signature, body)
+	if err != nil {
+		return false, err
+	}
+	if !e.Status.Final() {
+		return false, nil
+	}
+	_, err = s.payments.ApplyPaymentEvent(ctx, store.PaymentEvent{
+		Provider:      s.provider.Name(),
+		EventID:       e.ID,
+		TransactionID: e.TransactionID,
+		Status:        e.Status,
+		Payload:       body,
+	}, orderstate.PaymentActor(s.provider.Name()))
+	if errors.Is(err, store.ErrConflict) {
+		return true, nil
+	}
+	return false, err
+}
+
+func (s *PaymentService) createPayment(ctx context.Context, o *store.Order) (*store.Payment, error) {
+	intent, err := s.provider.CreateIntent(ctx, payment.IntentRequest{
+		Reference:   strconv.Itoa(o.ID),
+		Amount:      toCents(o.TotalPrice),
+		Description: fmt.Sprintf("订单 %d", o.ID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	p := &store.Payment{
+		OrderID:       o.ID,
+		Provider:      s.provider.Name(),
+		TransactionID: intent.TransactionID,
+		Amount:        o.TotalPrice,
+		PayURL:        intent.PayURL,
+	}
+	err = s.payments.CreatePayment(ctx, p)
+	if errors.Is(err, store.ErrConflict) {
+		// 并发请求已为订单创建了待支付记录，沿用该记录；刚创建的交易不会被付款，由渠道超时关闭
+		return s.payments.LatestPayment(ctx, o.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// sync 向渠道查询待支付记录的状态，已有结果时结算支付并推进订单
+func (s *PaymentService) sync(ctx context.Context, p *store.Payment) (*store.Payment, error) {
+	if p.Status != payment.StatusPending {
+		return p, nil
+	}
+	status, err := s.provider.QueryPayment(ctx, p.TransactionID)
+	if errors.Is(err, payment.ErrUnknownTransaction) {
+		// 渠道中已没有该交易（如模拟渠道重启），按失败处理，用户可重新发起支付
+		status, err = payment.StatusFailed, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !status.Final() {
+		return p, nil
+	}
+	// 其他请求可能已先结算，此时 ErrInvalidState 可忽略，重新读取即可
+	_, err = s.payments.SettlePayment(ctx, p.ID, status, orderstate.PaymentActor(p.Provider))
+	if err != nil && !errors.Is(err, store.ErrInvalidState) {
+		return nil, err
+	}
+	if p, err = s.payments.GetPayment(ctx, p.ID); err != nil {
+		return nil, err
+	}
+	if p.NeedsRefund && !p.Refunded {
+		log.Printf("支付 %d 成功时订单 %d 已不是待付款，等待原路退回", p.ID, p.OrderID)
+	}
+	return p, nil
+}
+
+// RefundUnmatched 把支付成功但订单未因此付款（订单已取消或已由其他支付付款）的支付原路全额退回，返回退回的笔数。
+// 退款单号固定为 "payment-<支付ID>"，中途失败后重试不会重复退款；单笔失败时记录日志并继续处理下一笔
+func (s *PaymentService) RefundUnmatched(ctx context.Context, limit int) (int, error) {
+	list, err := s.payments.ListPaymentsToRefund(ctx, limit)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, p := range list {
+		if p.Provider != s.provider.Name() {
+			continue
+		}
+		var refundID string
+		if amount := toCents(p.Amount); amount > 0 {
+			refundID, err = s.provider.Refund(ctx, p.TransactionID, amount, "payment-"+strconv.Itoa(p.ID))
+			if err != nil {
+				if ctx.Err() != nil {
+					return n, ctx.Err()
+				}
+				log.Printf("退回支付 %d（订单 %d，交易 %s）失败: %v", p.ID, p.OrderID, p.TransactionID, err)
+				continue
+			}
+		}
+		err = s.payments.MarkPaymentRefunded(ctx, p.ID, refundID)
+		if errors.Is(err, store.ErrInvalidState) {
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		log.Printf("支付 %d 成功时订单 %d 已不是待付款，已原路退回 %.2f", p.ID, p.OrderID, p.Amount)
+		n++
+	}
+	return n, nil
+}
+
+// refreshPending 订单最近一次支付仍为待支付时向渠道查询一次并结算，用于取消超时订单前确认用户没有已付款
+func (s *PaymentService) refreshPending(ctx context.Context, orderID int) error {
+	p, err := s.payments.LatestPayment(ctx, orderID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = s.sync(ctx, p)
+	return err
+}
@@ -0,0 +1,89 @@
+package service_test
+
+import (
+	"back/orderstate"
+	"back/payment"
+	"back/service"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// createOrder 为用户 1 下单一件型号 10，返回订单 ID
+func createOrder(t *testing.T, svc *service.Services) int {
+	t.Helper()
+	id, _, err := svc.Orders.Create(context.Background(), 1, "user:alice", service.CreateOrderInput{
+		Items: []service.OrderItemInput{{ProductID: 1, ModelID: 10, Quantity: 1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestExpiredOrderPaymentIsRefunded(t *testing.T) {
+	svc, db, mock := newTestServicesWithMock(t)
+	ctx := context.Background()
+	now := time.Now()
+	mock.SetClock(func() time.Time { return now })
+
+	// A 的交易 1 分钟后成功，B 的交易 10 分钟后才成功
+	mock.SetOutcome(payment.MockSuccess, time.Minute)
+	a := createOrder(t, svc)
+	if p, err := svc.Payments.Pay(ctx, 1, a); err != nil || p.Status != payment.StatusPending {
+		t.Fatalf("Pay(A) = %+v, %v", p, err)
+	}
+	mock.SetOutcome(payment.MockSuccess, 10*time.Minute)
+	b := createOrder(t, svc)
+	if _, err := svc.Payments.Pay(ctx, 1, b); err != nil {
+		t.Fatal(err)
+	}
+
+	// 超时扫描时 A 已在渠道付款，不会被取消；B 仍未付款，被取消并归还库存
+	now = now.Add(2 * time.Minute)
+	n, err := svc.Orders.ExpirePending(ctx, -time.Minute, 10, "超时")
+	if err != nil || n != 1 {
+		t.Fatalf("ExpirePending = %d, %v, want 1", n, err)
+	}
+	for id, want := range map[int]orderstate.Status{a: orderstate.ToShip, b: orderstate.Cancelled} {
+		if o, _ := svc.Orders.Detail(ctx, 1, id); o.Status != want {
+			t.Errorf("order %d status = %s, want %s", id, o.Status, want)
+		}
+	}
+	if stock := db.ModelStock(10); stock != 4 {
+		t.Errorf("stock = %d, want 4", stock)
+	}
+
+	// B 在取消后付款成功：记为成功并标记需要退回，订单不恢复
+	now = now.Add(10 * time.Minute)
+	p, err := svc.Payments.Status(ctx, 1, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Status != payment.StatusSucceeded || !p.NeedsRefund || p.Refunded {
+		t.Fatalf("payment of cancelled order = %+v", p)
+	}
+	if o, _ := svc.Orders.Detail(ctx, 1, b); o.Status != orderstate.Cancelled {
+		t.Errorf("cancelled order status = %s", o.Status)
+	}
+
+	n, err = svc.Payments.RefundUnmatched(ctx, 10)
+	if err != nil || n != 1 {
+		t.Fatalf("RefundUnmatched = %d, %v, want 1", n, err)
+	}
+	if n, _ = svc.Payments.RefundUnmatched(ctx, 10); n != 0 {
+		t.Errorf("second RefundUnmatched = %d, want 0", n)
+	}
+	if p, _ = svc.Payments.Status(ctx, 1, b); !p.Refunded {
+		t.Errorf("payment not marked refunded: %+v", p)
+	}
+	// 渠道侧已全额退款，无法再退
+	if _, err := mock.Refund(ctx, p.TransactionID, 1, "extra"); !errors.Is(err, payment.ErrNotRefundable) {
+		t.Errorf("extra refund error = %v, want ErrNotRefundable", err)
+	}
+	// A 的支付使订单付款，不会被退回
+	if p, _ := svc.Payments.Status(ctx, 1, a); p.NeedsRefund {
+		t.Errorf("paid order payment marked for refund: %+v", p)
+	}
+}
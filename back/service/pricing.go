@@ -0,0 +1,12 @@
+package service
+
+import "math"
+
+// 金额统一换算成分再计算，避免浮点误差
+func toCents(v float64) int64 {
+	return int64(math.Round(v * 100))
+}
+
+func fromCents(v int64) float64 {
+	return float64(v) / 100
+}
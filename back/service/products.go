@@ -0,0 +1,141 @@
+package service
+
+import (
+	"back/store"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	detailReviewLimit = 10 // 商品详情中附带的最新评价条数
+	listDefaultLimit  = 20 // 商品列表默认每页条数
+	listMaxLimit      = 100
+)
+
+// ProductService 商品浏览
+type ProductService struct {
+	products   store.ProductStore
+	categories store.CategoryStore
+	reviews    store.ReviewStore
+}
+
+// ProductDetail 商品详情及最新评价
+type ProductDetail struct {
+	store.Product
+	Reviews []store.Review
+}
+
+// ListInput 商品列表参数，Cursor 为上一页返回的 NextCursor
+type ListInput struct {
+	Category CategoryFilter // 包含子孙分类
+	MinPrice *float64
+	MaxPrice *float64
+	InStock  bool
+	Sort     string
+	Cursor   string
+	Limit    int
+}
+
+// ProductList 一页商品，NextCursor 为空表示没有下一页
+type ProductList struct {
+	Items      []store.ProductSummary
+	Total      int
+	NextCursor string
+}
+
+// List 商品列表，默认按最新上架排序，参数不合法时返回 ErrInvalidInput，
+// 分类不存在时返回 store.ErrNotFound
+func (s *ProductService) List(ctx context.Context, in ListInput) (*ProductList, error) {
+	q := store.ProductQuery{
+		MinPrice: in.MinPrice,
+		MaxPrice: in.MaxPrice,
+		InStock:  in.InStock,
+		Sort:     store.ProductSort(in.Sort),
+		Limit:    in.Limit,
+	}
+	if q.Sort == "" {
+		q.Sort = store.SortNewest
+	}
+	if !q.Sort.Valid() {
+		return nil, ErrInvalidInput
+	}
+	if (q.MinPrice != nil && *q.MinPrice < 0) || (q.MaxPrice != nil && *q.MaxPrice < 0) ||
+		(q.MinPrice != nil && q.MaxPrice != nil && *q.MinPrice > *q.MaxPrice) {
+		return nil, ErrInvalidInput
+	}
+	if q.Limit <= 0 {
+		q.Limit = listDefaultLimit
+	}
+	if q.Limit > listMaxLimit {
+		q.Limit = listMaxLimit
+	}
+	if in.Cursor != "" {
+		after, err := decodeCursor(in.Cursor, q.Sort)
+		if err != nil {
+			return nil, err
+		}
+		q.After = after
+	}
+	categoryIDs, err := resolveCategoryFilter(ctx, s.categories, in.Category)
+	if err != nil {
+		return nil, err
+	}
+	q.CategoryIDs = categoryIDs
+	page, err := s.products.ListProducts(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	list := &ProductList{Items: page.Items, Total: page.Total}
+	if page.Next != nil {
+		list.NextCursor = encodeCursor(q.Sort, page.Next)
+	}
+	return list, nil
+}
+
+// 游标格式：base64url("排序方式:排序键:id")，只能用于生成它的排序方式
+func encodeCursor(sort store.ProductSort, c *store.ProductCursor) string {
+	raw := fmt.Sprintf("%s:%s:%d", sort, strconv.FormatFloat(c.Key, 'f', -1, 64), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string, sort store.ProductSort) (*store.ProductCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidInput
+	}
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 || store.ProductSort(parts[0]) != sort {
+		return nil, ErrInvalidInput
+	}
+	key, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil || math.IsNaN(key) || math.IsInf(key, 0) {
+		return nil, ErrInvalidInput
+	}
+	id, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return nil, ErrInvalidInput
+	}
+	return &store.ProductCursor{Key: key, ID: id}, nil
+}
+
+// Detail 商品详情，不存在时返回 store.ErrNotFound
+func (s *ProductService) Detail(ctx context.Context, id int) (*ProductDetail, error) {
+	p, err := s.products.GetProduct(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	reviews, err := s.reviews.ListReviews(ctx, store.ReviewQuery{
+		ProductID: id,
+		Status:    store.ReviewApproved,
+		Sort:      store.ReviewSortNewest,
+		Limit:     detailReviewLimit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ProductDetail{Product: *p, Reviews: reviews.Items}, nil
+}
@@ -0,0 +1,92 @@
+package service_test
+
+import (
+	"back/service"
+	"back/store"
+	"back/store/memory"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// addProducts 在基础数据的两个商品之后追加 n 个商品，依次为商品 3、4…，价格为 1、2…
+func addProducts(db *memory.DB, n int) {
+	for i := 1; i <= n; i++ {
+		db.AddProduct(store.Product{ID: 2 + i, Title: "商品", Models: []store.Model{{ID: 1000 + i, Name: "默认", Price: float64(i), Stock: 1}}})
+	}
+}
+
+func TestProductListLimit(t *testing.T) {
+	svc, db := newTestServices(t)
+	addProducts(db, 118)
+	tests := []struct {
+		name  string
+		limit int
+		want  int
+	}{
+		{"default", 0, 20},
+		{"negative", -5, 20},
+		{"within range", 7, 7},
+		{"clamped to max", 1000, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := svc.Products.List(context.Background(), service.ListInput{Limit: tt.limit})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(list.Items) != tt.want || list.Total != 120 || list.NextCursor == "" {
+				t.Fatalf("got %d items, total %d, cursor %q", len(list.Items), list.Total, list.NextCursor)
+			}
+		})
+	}
+}
+
+func TestProductListCursor(t *testing.T) {
+	svc, db := newTestServices(t)
+	addProducts(db, 3)
+	ctx := context.Background()
+
+	// 按价格升序逐页读取，不重复不遗漏
+	var ids []int
+	in := service.ListInput{Sort: "price_asc", Limit: 2}
+	for {
+		list, err := svc.Products.List(ctx, in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range list.Items {
+			ids = append(ids, p.ID)
+		}
+		if list.NextCursor == "" {
+			break
+		}
+		in.Cursor = list.NextCursor
+	}
+	// 基础数据中商品 2 最便宜、商品 1 最贵
+	if want := []int{2, 3, 4, 5, 1}; !slices.Equal(ids, want) {
+		t.Fatalf("pages = %v, want %v", ids, want)
+	}
+
+	first, err := svc.Products.List(ctx, service.ListInput{Sort: "price_asc", Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		in   service.ListInput
+	}{
+		{"cursor from another sort", service.ListInput{Sort: "price_desc", Cursor: first.NextCursor}},
+		{"cursor with default sort", service.ListInput{Cursor: first.NextCursor}},
+		{"malformed cursor", service.ListInput{Sort: "price_asc", Cursor: "%%%"}},
+		{"unknown sort", service.ListInput{Sort: "cheapest"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := svc.Products.List(ctx, tt.in); !errors.Is(err, service.ErrInvalidInput) {
+				t.Fatalf("List error = %v, want ErrInvalidInput", err)
+			}
+		})
+	}
+}
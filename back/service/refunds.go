@@ -0,0 +1,173 @@
+package service
+
+import (
+	"back/orderstate"
+	"back/payment"
+	"back/store"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	refundMaxReasonLen    = 200 // 退款原因最大字符数
+	refundMaxImages       = 6   // 每个申请最多凭证图片数
+	refundMaxNoteLen      = 200
+	refundDefaultPageSize = 10
+	refundMaxPageSize     = 50
+	refundQueuePageSize   = 20
+)
+
+// ErrNotRefundable 订单当前状态不可申请该类型的售后
+var ErrNotRefundable = errors.New("订单当前状态不可申请退款")
+
+// ErrRefundExists 该订单明细已有处理中或已完成的申请
+var ErrRefundExists = errors.New("该商品已申请过退款")
+
+// ErrRefundProcessed 申请已处理
+var ErrRefundProcessed = errors.New("退款申请已处理")
+
+// ErrNoSettledPayment 订单没有成功的支付记录，无法原路退款
+var ErrNoSettledPayment = errors.New("订单没有成功的支付记录，无法原路退款")
+
+// 各售后类型允许申请的订单状态：未发货只能仅退款，发货后可以退货
+var refundableStatuses = map[store.RefundType][]orderstate.Status{
+	store.RefundOnly:   {orderstate.ToShip, orderstate.ToReceive, orderstate.ToReview},
+	store.RefundReturn: {orderstate.ToReceive, orderstate.ToReview},
+}
+
+// RefundService 订单明细的退款/退货申请与审核
+type RefundService struct {
+	refunds  store.RefundStore
+	payments store.PaymentStore
+	provider payment.Provider
+}
+
+// RefundInput 退款申请参数，一次申请退回整个明细
+type RefundInput struct {
+	OrderID     int
+	OrderItemID int
+	Type        string // refund（仅退款）或 return（退货退款）
+	Reason      string
+	ImageKeys   []string // 通过 /refunds/images 上传得到的对象 key，不是访问地址
+}
+
+// Request 为订单明细提交退款申请，金额为明细单价 × 数量。
+// 订单或明细不存在时返回 store.ErrNotFound
+func (s *RefundService) Request(ctx context.Context, userID int, in RefundInput) (*store.Refund, error) {
+	in.Reason = strings.TrimSpace(in.Reason)
+	allowed, ok := refundableStatuses[store.RefundType(in.Type)]
+	if !ok || in.OrderID <= 0 || in.OrderItemID <= 0 || in.Reason == "" ||
+		utf8.RuneCountInString(in.Reason) > refundMaxReasonLen || len(in.ImageKeys) > refundMaxImages {
+		return nil, ErrInvalidInput
+	}
+	r := &store.Refund{
+		OrderID:     in.OrderID,
+		OrderItemID: in.OrderItemID,
+		UserID:      userID,
+		Type:        store.RefundType(in.Type),
+		Reason:      in.Reason,
+		ImageKeys:   in.ImageKeys,
+	}
+	err := s.refunds.CreateRefund(ctx, r, allowed)
+	switch {
+	case errors.Is(err, store.ErrInvalidState):
+		return nil, ErrNotRefundable
+	case errors.Is(err, store.ErrConflict):
+		return nil, ErrRefundExists
+	case err != nil:
+		return nil, err
+	}
+	return r, nil
+}
+
+// List 用户的退款申请，最新在前，orderID 为 0 时返回全部订单的申请
+func (s *RefundService) List(ctx context.Context, userID, orderID, page int) (*store.RefundPage, error) {
+	offset, limit, err := pageBounds(page, refundDefaultPageSize, refundDefaultPageSize, refundMaxPageSize)
+	if err != nil {
+		return nil, err
+	}
+	return s.refunds.ListRefunds(ctx, store.RefundQuery{UserID: userID, OrderID: orderID, Offset: offset, Limit: limit})
+}
+
+// Queue 售后处理队列，按申请时间先后排列，status 为空时返回待处理申请
+func (s *RefundService) Queue(ctx context.Context, status string, page int) (*store.RefundPage, error) {
+	st := store.RefundStatus(status)
+	if st == "" {
+		st = store.RefundRequested
+	}
+	if !validRefundStatus(st) {
+		return nil, ErrInvalidInput
+	}
+	offset, limit, err := pageBounds(page, refundQueuePageSize, refundQueuePageSize, refundQueuePageSize)
+	if err != nil {
+		return nil, err
+	}
+	return s.refunds.ListRefunds(ctx, store.RefundQuery{Status: st, Oldest: true, Offset: offset, Limit: limit})
+}
+
+// Reject 拒绝待处理的申请，必须填写原因；申请不存在时返回 store.ErrNotFound
+func (s *RefundService) Reject(ctx context.Context, refundID int, note, actor string) error {
+	note = strings.TrimSpace(note)
+	if note == "" || utf8.RuneCountInString(note) > refundMaxNoteLen {
+		return ErrInvalidInput
+	}
+	err := s.refunds.RejectRefund(ctx, refundID, actor, note)
+	if errors.Is(err, store.ErrInvalidState) {
+		return ErrRefundProcessed
+	}
+	return err
+}
+
+// Approve 同意申请：退货退款或未发货时归还库存，再向支付渠道原路退款，成功后标记为已退款，
+// 订单全部明细都已退款时订单流转为退款状态。
+// 订单没有成功的支付时返回 ErrNoSettledPayment，申请保持待处理，可以拒绝后线下处理。
+// 渠道退款失败时申请停留在已同意状态，再次调用会以相同的退款单号重试，不会重复退款或重复归还库存
+func (s *RefundService) Approve(ctx context.Context, refundID int, note, actor string) (*store.Refund, error) {
+	note = strings.TrimSpace(note)
+	if utf8.RuneCountInString(note) > refundMaxNoteLen {
+		return nil, ErrInvalidInput
+	}
+	r, err := s.refunds.GetRefund(ctx, refundID)
+	if err != nil {
+		return nil, err
+	}
+	if r.Status != store.RefundRequested && r.Status != store.RefundApproved {
+		return nil, ErrRefundProcessed
+	}
+	// 先确认能原路退款再变更申请和库存；成功的支付不会再变化，无需与下面的事务一起加锁
+	p, err := s.payments.OrderPayment(ctx, r.OrderID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrNoSettledPayment
+	}
+	if err != nil {
+		return nil, err
+	}
+	r, err = s.refunds.ApproveRefund(ctx, refundID, actor, note)
+	if errors.Is(err, store.ErrInvalidState) {
+		return nil, ErrRefundProcessed
+	}
+	if err != nil {
+		return nil, err
+	}
+	// 零元明细无需调用渠道
+	var providerRefundID string
+	if amount := toCents(r.Amount); amount > 0 {
+		providerRefundID, err = s.provider.Refund(ctx, p.TransactionID, amount, "refund-"+strconv.Itoa(r.ID))
+		if err != nil {
+			return nil, err
+		}
+	}
+	// 并发的重试已先完成时 ErrInvalidState 可忽略
+	_, err = s.refunds.CompleteRefund(ctx, r.ID, providerRefundID, actor)
+	if err != nil && !errors.Is(err, store.ErrInvalidState) {
+		return nil, err
+	}
+	return s.refunds.GetRefund(ctx, r.ID)
+}
+
+func validRefundStatus(s store.RefundStatus) bool {
+	return s == store.RefundRequested || s == store.RefundApproved || s == store.RefundRejected || s == store.RefundCompleted
+}
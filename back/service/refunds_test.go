@@ -0,0 +1,116 @@
+package service_test
+
+import (
+	"back/orderstate"
+	"back/service"
+	"back/store"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRefundApprove(t *testing.T) {
+	tests := []struct {
+		name       string
+		pay        bool                // 是否通过支付渠道付款，否则直接把订单标记为待发货
+		ship       []orderstate.Status // 付款后依次流转的状态
+		typ        string
+		wantErr    error
+		wantStatus store.RefundStatus
+		wantStock  int // 型号 10 的库存，下单 2 件前为 5
+		wantOrder  orderstate.Status
+	}{
+		{
+			name:       "refund before shipping restocks",
+			pay:        true,
+			typ:        "refund",
+			wantStatus: store.RefundCompleted,
+			wantStock:  5,
+			wantOrder:  orderstate.Refund,
+		},
+		{
+			name:       "refund only after shipping keeps stock",
+			pay:        true,
+			ship:       []orderstate.Status{orderstate.ToReceive},
+			typ:        "refund",
+			wantStatus: store.RefundCompleted,
+			wantStock:  3,
+			wantOrder:  orderstate.Refund,
+		},
+		{
+			name:       "return after shipping restocks",
+			pay:        true,
+			ship:       []orderstate.Status{orderstate.ToReceive, orderstate.ToReview},
+			typ:        "return",
+			wantStatus: store.RefundCompleted,
+			wantStock:  5,
+			wantOrder:  orderstate.Refund,
+		},
+		{
+			name:       "no settled payment leaves request untouched",
+			typ:        "refund",
+			wantErr:    service.ErrNoSettledPayment,
+			wantStatus: store.RefundRequested,
+			wantStock:  3,
+			wantOrder:  orderstate.ToShip,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, db := newTestServices(t)
+			ctx := context.Background()
+			orders := db.Stores().Orders
+			orderID, _, err := svc.Orders.Create(ctx, 1, "user:alice", service.CreateOrderInput{
+				Items: []service.OrderItemInput{{ProductID: 1, ModelID: 10, Quantity: 2}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.pay {
+				if _, err := svc.Payments.Pay(ctx, 1, orderID); err != nil {
+					t.Fatal(err)
+				}
+			} else if err := orders.TransitionOrder(ctx, orderID, orderstate.ToShip, "staff:s", ""); err != nil {
+				t.Fatal(err)
+			}
+			for _, st := range tt.ship {
+				if err := orders.TransitionOrder(ctx, orderID, st, "staff:s", ""); err != nil {
+					t.Fatal(err)
+				}
+			}
+			o, err := orders.GetOrder(ctx, 1, orderID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := svc.Refunds.Request(ctx, 1, service.RefundInput{
+				OrderID: orderID, OrderItemID: o.Items[0].ID, Type: tt.typ, Reason: "不想要了",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = svc.Refunds.Approve(ctx, r.ID, "", "staff:s")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Approve error = %v, want %v", err, tt.wantErr)
+			}
+			got, err := svc.Refunds.Queue(ctx, string(tt.wantStatus), 1)
+			if err != nil || got.Total != 1 {
+				t.Errorf("refunds with status %s = %v, %v", tt.wantStatus, got, err)
+			}
+			if stock := db.ModelStock(10); stock != tt.wantStock {
+				t.Errorf("stock = %d, want %d", stock, tt.wantStock)
+			}
+			if o, _ := orders.GetOrder(ctx, 1, orderID); o.Status != tt.wantOrder {
+				t.Errorf("order status = %s, want %s", o.Status, tt.wantOrder)
+			}
+			// 再次同意不会重复归还库存
+			_, err = svc.Refunds.Approve(ctx, r.ID, "", "staff:s")
+			if tt.wantErr == nil && !errors.Is(err, service.ErrRefundProcessed) {
+				t.Errorf("second Approve error = %v, want ErrRefundProcessed", err)
+			}
+			if stock := db.ModelStock(10); stock != tt.wantStock {
+				t.Errorf("stock after retry = %d, want %d", stock, tt.wantStock)
+			}
+		})
+	}
+}
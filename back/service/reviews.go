@@ -0,0 +1,156 @@
+package service
+
+import (
+	"back/store"
+	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	reviewMaxContentLen     = 500 // 评价内容最大字符数
+	reviewMaxImages         = 6   // 每条评价最多图片数
+	reviewDefaultPageSize   = 10
+	reviewMaxPageSize       = 50
+	moderationQueuePageSize = 20
+)
+
+// ErrNotReviewable 订单不在待评价状态
+var ErrNotReviewable = errors.New("订单当前状态不可评价")
+
+// ErrAlreadyReviewed 该订单明细已评价
+var ErrAlreadyReviewed = errors.New("该商品已评价")
+
+// ErrOwnReview 不能给自己的评价投票
+var ErrOwnReview = errors.New("不能给自己的评价投票")
+
+// ReviewInput 购买评价，一个订单明细只能评价一次
+type ReviewInput struct {
+	OrderItemID int
+	Rating      int
+	Content     string
+	Images      []string // 已上传图片的 URL
+}
+
+// Review 评价待评价订单中的一个明细，返回订单是否因此完成（全部明细已评价）。
+// 带文字或图片的评价需审核通过后才展示，只有评分的评价直接通过。
+// 订单或明细不存在时返回 store.ErrNotFound
+func (s *OrderService) Review(ctx context.Context, userID int, username string, orderID int, in ReviewInput, actor string) (bool, error) {
+	in.Content = strings.TrimSpace(in.Content)
+	if in.OrderItemID <= 0 || in.Rating < 1 || in.Rating > 5 ||
+		utf8.RuneCountInString(in.Content) > reviewMaxContentLen || len(in.Images) > reviewMaxImages {
+		return false, ErrInvalidInput
+	}
+	status := store.ReviewApproved
+	if in.Content != "" || len(in.Images) > 0 {
+		status = store.ReviewPending
+	}
+	completed, err := s.orders.ReviewOrderItem(ctx, orderID, store.NewReview{
+		UserID:      userID,
+		Username:    username,
+		OrderItemID: in.OrderItemID,
+		Rating:      in.Rating,
+		Content:     in.Content,
+		Images:      in.Images,
+		Status:      status,
+	}, actor)
+	switch {
+	case errors.Is(err, store.ErrInvalidState):
+		return false, ErrNotReviewable
+	case errors.Is(err, store.ErrConflict):
+		return false, ErrAlreadyReviewed
+	}
+	return completed, err
+}
+
+// ReviewService 评价列表、审核和投票
+type ReviewService struct {
+	reviews store.ReviewStore
+}
+
+// ReviewListInput 商品评价列表参数，Page 从 1 开始
+type ReviewListInput struct {
+	Sort     string // newest（默认）或 helpful
+	Rating   int    // 0 表示全部星级
+	Page     int
+	PageSize int
+}
+
+// ProductReviews 商品的已通过评价
+func (s *ReviewService) ProductReviews(ctx context.Context, productID int, in ReviewListInput) (*store.ReviewPage, error) {
+	sort := store.ReviewSort(in.Sort)
+	if sort == "" {
+		sort = store.ReviewSortNewest
+	}
+	if (sort != store.ReviewSortNewest && sort != store.ReviewSortHelpful) || in.Rating < 0 || in.Rating > 5 {
+		return nil, ErrInvalidInput
+	}
+	offset, limit, err := pageBounds(in.Page, in.PageSize, reviewDefaultPageSize, reviewMaxPageSize)
+	if err != nil {
+		return nil, err
+	}
+	return s.reviews.ListReviews(ctx, store.ReviewQuery{
+		ProductID: productID,
+		Status:    store.ReviewApproved,
+		Rating:    in.Rating,
+		Sort:      sort,
+		Offset:    offset,
+		Limit:     limit,
+	})
+}
+
+// ModerationQueue 审核队列，按提交时间先后排列，status 为空时返回待审核评价
+func (s *ReviewService) ModerationQueue(ctx context.Context, status string, page int) (*store.ReviewPage, error) {
+	st := store.ReviewStatus(status)
+	if st == "" {
+		st = store.ReviewPending
+	}
+	if !validReviewStatus(st) {
+		return nil, ErrInvalidInput
+	}
+	offset, limit, err := pageBounds(page, moderationQueuePageSize, moderationQueuePageSize, moderationQueuePageSize)
+	if err != nil {
+		return nil, err
+	}
+	return s.reviews.ListReviews(ctx, store.ReviewQuery{Status: st, Sort: store.ReviewSortOldest, Offset: offset, Limit: limit})
+}
+
+// Moderate 变更评价审核状态，评价不存在时返回 store.ErrNotFound
+func (s *ReviewService) Moderate(ctx context.Context, reviewID int, status, actor string) error {
+	st := store.ReviewStatus(status)
+	if !validReviewStatus(st) {
+		return ErrInvalidInput
+	}
+	return s.reviews.SetReviewStatus(ctx, reviewID, st, actor)
+}
+
+// Vote 标记或取消评价有用，返回最新有用数；评价不存在或未通过审核时返回 store.ErrNotFound
+func (s *ReviewService) Vote(ctx context.Context, reviewID, userID int, helpful bool) (int, error) {
+	count, err := s.reviews.VoteHelpful(ctx, reviewID, userID, helpful)
+	if errors.Is(err, store.ErrInvalidState) {
+		return 0, ErrOwnReview
+	}
+	return count, err
+}
+
+func validReviewStatus(s store.ReviewStatus) bool {
+	return s == store.ReviewPending || s == store.ReviewApproved || s == store.ReviewHidden
+}
+
+// pageBounds 把页码和每页条数换算为 offset/limit，page 为 0 时视为第一页
+func pageBounds(page, pageSize, defaultSize, maxSize int) (offset, limit int, err error) {
+	if page < 0 || pageSize < 0 {
+		return 0, 0, ErrInvalidInput
+	}
+	if page == 0 {
+		page = 1
+	}
+	if pageSize == 0 {
+		pageSize = defaultSize
+	}
+	if pageSize > maxSize {
+		pageSize = maxSize
+	}
+	return (page - 1) * pageSize, pageSize, nil
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"back/store"
+	"context"
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	searchMaxQueryLen   = 64 // 搜索词最大字符数
+	searchMaxTerms      = 5  // 最多关键词个数
+	searchDefaultLimit  = 20
+	searchMaxLimit      = 50
+	snippetLen          = 60 // 描述摘要字符数
+	snippetLeadingChars = 15 // 摘要中首个命中位置之前保留的字符数
+)
+
+// SearchResult 搜索结果，TitleHighlight、DescriptionHighlight 已做 HTML 转义，命中片段以 <em> 包裹
+type SearchResult struct {
+	store.ProductSummary
+	TitleHighlight       string
+	DescriptionHighlight string
+	Rank                 float64
+}
+
+// Search 按关键词搜索商品标题和描述，多个关键词以空白分隔且须同时命中；
+// 可按分类（含子孙分类）筛选，limit <= 0 时使用默认条数
+func (s *ProductService) Search(ctx context.Context, query string, category CategoryFilter, limit int) ([]SearchResult, error) {
+	terms, err := parseSearchQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	categoryIDs, err := resolveCategoryFilter(ctx, s.categories, category)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = searchDefaultLimit
+	}
+	if limit > searchMaxLimit {
+		limit = searchMaxLimit
+	}
+	found, err := s.products.SearchProducts(ctx, store.ProductSearch{Terms: terms, CategoryIDs: categoryIDs, Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	results := make([]SearchResult, 0, len(found))
+	for _, f := range found {
+		results = append(results, SearchResult{
+			ProductSummary:       f.ProductSummary,
+			TitleHighlight:       highlight(f.Title, terms, 0),
+			DescriptionHighlight: highlight(f.Description, terms, snippetLen),
+			Rank:                 f.Rank,
+		})
+	}
+	return results, nil
+}
+
+// parseSearchQuery 拆分关键词并去重
+func parseSearchQuery(query string) ([]string, error) {
+	query = strings.TrimSpace(query)
+	if query == "" || utf8.RuneCountInString(query) > searchMaxQueryLen {
+		return nil, ErrInvalidInput
+	}
+	terms := []string{}
+	seen := map[string]bool{}
+	for _, t := range strings.Fields(query) {
+		key := strings.ToLower(t)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		terms = append(terms, t)
+	}
+	if len(terms) > searchMaxTerms {
+		return nil, ErrInvalidInput
+	}
+	return terms, nil
+}
+
+// highlight 将 text 中大小写不敏感命中的关键词用 <em> 包裹，其余内容做 HTML 转义。
+// maxLen > 0 时截取以首个命中位置为中心、长度为 maxLen 的摘要
+func highlight(text string, terms []string, maxLen int) string {
+	runes := []rune(text)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	marked := make([]bool, len(runes))
+	first := -1
+	for _, t := range terms {
+		term := []rune(strings.ToLower(t))
+		for i := 0; i+len(term) <= len(lower); i++ {
+			if !hasRunePrefix(lower[i:], term) {
+				continue
+			}
+			for j := i; j < i+len(term); j++ {
+				marked[j] = true
+			}
+			if first < 0 || i < first {
+				first = i
+			}
+		}
+	}
+
+	start, end := 0, len(runes)
+	if maxLen > 0 && len(runes) > maxLen {
+		if first > snippetLeadingChars {
+			start = first - snippetLeadingChars
+		}
+		if start+maxLen > len(runes) {
+			start = len(runes) - maxLen
+		}
+		end = start + maxLen
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i := start; i < end; {
+		j := i
+		for j < end && marked[j] == marked[i] {
+			j++
+		}
+		segment := html.EscapeString(string(runes[i:j]))
+		if marked[i] {
+			b.WriteString("<em>" + segment + "</em>")
+		} else {
+			b.WriteString(segment)
+		}
+		i = j
+	}
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+func hasRunePrefix(s, prefix []rune) bool {
+	for i, r := range prefix {
+		if s[i] != r {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"back/payment"
+	"back/store"
+	"errors"
+)
+
+// ErrInvalidInput 请求参数不合法
+var ErrInvalidInput = errors.New("参数错误")
+
+// Services 全部业务服务
+type Services struct {
+	Users       *UserService
+	Products    *ProductService
+	Categories  *CategoryService
+	Reviews     *ReviewService
+	Addresses   *AddressService
+	Carts       *CartService
+	Orders      *OrderService
+	Payments    *PaymentService
+	Refunds     *RefundService
+	Idempotency *IdempotencyService
+}
+
+// New 基于给定存储和支付渠道创建全部服务
+func New(s store.Stores, provider payment.Provider) *Services {
+	addresses := &AddressService{addresses: s.Addresses, users: s.Users}
+	payments := &PaymentService{payments: s.Payments, orders: s.Orders, provider: provider}
+	return &Services{
+		Users:       &UserService{users: s.Users},
+		Products:    &ProductService{products: s.Products, categories: s.Categories, reviews: s.Reviews},
+		Categories:  &CategoryService{categories: s.Categories},
+		Reviews:     &ReviewService{reviews: s.Reviews},
+		Addresses:   addresses,
+		Carts:       &CartService{carts: s.Carts},
+		Orders:      &OrderService{orders: s.Orders, addresses: addresses, payments: payments},
+		Payments:    payments,
+		Refunds:     &RefundService{refunds: s.Refunds, payments: s.Payments, provider: provider},
+		Idempotency: &IdempotencyService{keys: s.Idempotency},
+	}
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"back/auth"
+	"back/store"
+	"context"
+	"errors"
+	"log"
+)
+
+// ErrUsernameTaken 用户名已存在
+var ErrUsernameTaken = errors.New("用户名已存在")
+
+// ErrInvalidCredentials 用户名或密码错误
+var ErrInvalidCredentials = errors.New("用户名或密码错误")
+
+// UserService 用户注册、登录和资料维护
+type UserService struct {
+	users store.UserStore
+}
+
+// RegisterInput 注册参数
+type RegisterInput struct {
+	Username string
+	Password string
+	Nickname string
+	Address  string
+}
+
+// Register 注册新用户，密码以 bcrypt 哈希存储，超过 auth.MaxPasswordBytes 字节时返回 ErrInvalidInput
+func (s *UserService) Register(ctx context.Context, in RegisterInput) (*store.User, error) {
+	if in.Username == "" || in.Password == "" || len(in.Password) > auth.MaxPasswordBytes {
+		return nil, ErrInvalidInput
+	}
+	hash, err := auth.HashPassword(in.Password)
+	if err != nil {
+		return nil, err
+	}
+	u := &store.User{Username: in.Username, PasswordHash: hash, Nickname: in.Nickname, Address: in.Address}
+	err = s.users.CreateUser(ctx, u)
+	if errors.Is(err, store.ErrConflict) {
+		return nil, ErrUsernameTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// Login 校验用户名密码。旧的明文密码在登录成功后自动改存为哈希，改存失败不影响本次登录。
+func (s *UserService) Login(ctx context.Context, username, password string) (*store.User, error) {
+	u, err := s.users.GetUserByUsername(ctx, username)
+	if errors.Is(err, store.ErrNotFound) {
+		auth.CheckDummyPassword(password)
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	ok, needsRehash := auth.CheckPassword(u.PasswordHash, password)
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	if needsRehash {
+		if hash, err := auth.HashPassword(password); err == nil {
+			if err := s.users.UpdatePassword(ctx, u.ID, u.PasswordHash, hash); err != nil {
+				log.Printf("密码哈希迁移失败: %v", err)
+			}
+		}
+	}
+	return u, nil
+}
+
+// ByUsername 按用户名查找用户，不存在时返回 store.ErrNotFound
+func (s *UserService) ByUsername(ctx context.Context, username string) (*store.User, error) {
+	return s.users.GetUserByUsername(ctx, username)
+}
+
+// Profile 返回用户资料
+func (s *UserService) Profile(ctx context.Context, userID int) (*store.User, error) {
+	return s.users.GetUser(ctx, userID)
+}
+
+// UpdateAddress 修改收货地址
+func (s *UserService) UpdateAddress(ctx context.Context, userID int, address string) error {
+	if address == "" {
+		return ErrInvalidInput
+	}
+	return s.users.UpdateAddress(ctx, userID, address)
+}
+
+// UpdateNickname 修改昵称
+func (s *UserService) UpdateNickname(ctx context.Context, userID int, nickname string) error {
+	if nickname == "" {
+		return ErrInvalidInput
+	}
+	return s.users.UpdateNickname(ctx, userID, nickname)
+}
+
+// UpdateAvatar 修改头像地址，返回原头像地址，供调用方清理旧文件
+func (s *UserService) UpdateAvatar(ctx context.Context, userID int, avatar string) (string, error) {
+	return s.users.UpdateAvatar(ctx, userID, avatar)
+}
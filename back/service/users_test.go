@@ -0,0 +1,50 @@
+package service_test
+
+import (
+	"back/service"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRegister(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      service.RegisterInput
+		wantErr error
+	}{
+		{"success", service.RegisterInput{Username: "bob", Password: "secret"}, nil},
+		{"password at bcrypt limit", service.RegisterInput{Username: "bob", Password: strings.Repeat("密", 24)}, nil},
+		{"password over bcrypt limit", service.RegisterInput{Username: "bob", Password: strings.Repeat("a", 73)}, service.ErrInvalidInput},
+		{"empty password", service.RegisterInput{Username: "bob"}, service.ErrInvalidInput},
+		{"username taken", service.RegisterInput{Username: "alice", Password: "secret"}, service.ErrUsernameTaken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newTestServices(t)
+			ctx := context.Background()
+			_, err := svc.Users.Register(ctx, tt.in)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Register error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if _, err := svc.Users.Login(ctx, tt.in.Username, tt.in.Password); err != nil {
+					t.Fatalf("Login: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestLoginInvalidCredentials(t *testing.T) {
+	svc, _ := newTestServices(t)
+	ctx := context.Background()
+	if _, err := svc.Users.Login(ctx, "alice", "wrong"); !errors.Is(err, service.ErrInvalidCredentials) {
+		t.Fatalf("wrong password error = %v", err)
+	}
+	// 用户不存在时同样返回 ErrInvalidCredentials，且会做一次 bcrypt 比较
+	if _, err := svc.Users.Login(ctx, "nobody", "secret"); !errors.Is(err, service.ErrInvalidCredentials) {
+		t.Fatalf("unknown user error = %v", err)
+	}
+}
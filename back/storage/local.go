@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalURLPrefix 本地存储的访问路径前缀
+const LocalURLPrefix = "/uploads/"
+
+// Local 本地文件系统存储，只适合单实例部署。
+// 访问地址形如 /uploads/<key>?expires=<unix 时间>&sig=<HMAC>，由 ServeHTTP 校验后返回文件
+type Local struct {
+	dir    string
+	prefix string
+	secret []byte
+	expiry time.Duration
+	now    func() time.Time
+}
+
+// NewLocal 创建本地存储，文件保存在 dir 下，prefix 为访问路径前缀，secret 用于签名访问地址
+func NewLocal(dir, prefix string, secret []byte, expiry time.Duration) *Local {
+	return &Local{dir: dir, prefix: prefix, secret: secret, expiry: expiry, now: time.Now}
+}
+
+// Prefix 访问路径前缀，需要把该前缀下的请求交给 ServeHTTP
+func (l *Local) Prefix() string {
+	return l.prefix
+}
+
+func (l *Local) path(key string) string {
+	return filepath.Join(l.dir, filepath.FromSlash(key))
+}
+
+// Put 先写临时文件再改名，避免并发访问时读到写了一半的文件
+func (l *Local) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+	dst := l.path(key)
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+	err := os.Remove(l.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (l *Local) SignedURL(key string) (string, error) {
+	if !ValidKey(key) {
+		return "", ErrInvalidKey
+	}
+	expires := strconv.FormatInt(l.now().Add(l.expiry).Unix(), 10)
+	q := url.Values{"expires": {expires}, "sig": {l.sign(key, expires)}}
+	return l.prefix + escapeKey(key) + "?" + q.Encode(), nil
+}
+
+func (l *Local) sign(key, expires string) string {
+	mac := hmac.New(sha256.New, l.secret)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ServeHTTP 校验签名和有效期后返回文件，签名错误或已过期返回 403
+func (l *Local) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, l.prefix)
+	if key == r.URL.Path || !ValidKey(key) {
+		http.NotFound(w, r)
+		return
+	}
+	expires := r.URL.Query().Get("expires")
+	sig := r.URL.Query().Get("sig")
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || !hmac.Equal([]byte(sig), []byte(l.sign(key, expires))) || l.now().Unix() > exp {
+		http.Error(w, "链接无效或已过期", http.StatusForbidden)
+		return
+	}
+	f, err := os.Open(l.path(key))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.FormatInt(exp-l.now().Unix(), 10))
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
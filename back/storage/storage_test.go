@@ -0,0 +1,29 @@
+package storage
+
+import (
+	"back/config"
+	"bytes"
+	"testing"
+)
+
+func TestURLSecret(t *testing.T) {
+	cfg := config.Default()
+	cfg.Session.Secret = "session-secret-0123456789abcdef0123"
+
+	derived := urlSecret(&cfg)
+	if bytes.Equal(derived, []byte(cfg.Session.Secret)) {
+		t.Fatal("local URLs signed with the session secret")
+	}
+	if !bytes.Equal(derived, urlSecret(&cfg)) {
+		t.Fatal("derived secret not stable")
+	}
+	cfg.Session.Secret += "x"
+	if bytes.Equal(derived, urlSecret(&cfg)) {
+		t.Fatal("derived secret does not depend on session.secret")
+	}
+
+	cfg.Storage.URLSecret = "url-secret-0123456789abcdef0123456"
+	if got := urlSecret(&cfg); string(got) != cfg.Storage.URLSecret {
+		t.Fatalf("urlSecret = %q, want storage.url_secret", got)
+	}
+}
@@ -0,0 +1,128 @@
+package memory
+
+import (
+	"back/store"
+	"context"
+	"sort"
+)
+
+type addressStore struct {
+	db *DB
+}
+
+func (s *addressStore) ListAddresses(ctx context.Context, userID int) ([]store.Address, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	list := []store.Address{}
+	for _, a := range s.db.addresses {
+		if a.UserID == userID {
+			list = append(list, *a)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].IsDefault != list[j].IsDefault {
+			return list[i].IsDefault
+		}
+		return list[i].ID > list[j].ID
+	})
+	return list, nil
+}
+
+func (s *addressStore) GetAddress(ctx context.Context, userID, id int) (*store.Address, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	a, ok := s.db.addresses[id]
+	if !ok || a.UserID != userID {
+		return nil, store.ErrNotFound
+	}
+	cp := *a
+	return &cp, nil
+}
+
+func (s *addressStore) CreateAddress(ctx context.Context, a *store.Address, limit int) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	count := 0
+	for _, old := range s.db.addresses {
+		if old.UserID == a.UserID {
+			count++
+		}
+	}
+	if count >= limit {
+		return store.ErrLimitExceeded
+	}
+	if count == 0 {
+		a.IsDefault = true
+	}
+	if a.IsDefault {
+		s.clearDefault(a.UserID)
+	}
+	s.db.nextAddressID++
+	a.ID = s.db.nextAddressID
+	cp := *a
+	s.db.addresses[a.ID] = &cp
+	return nil
+}
+
+func (s *addressStore) UpdateAddress(ctx context.Context, a *store.Address) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	old, ok := s.db.addresses[a.ID]
+	if !ok || old.UserID != a.UserID {
+		return store.ErrNotFound
+	}
+	if a.IsDefault {
+		s.clearDefault(a.UserID)
+	}
+	a.IsDefault = a.IsDefault || old.IsDefault
+	cp := *a
+	s.db.addresses[a.ID] = &cp
+	return nil
+}
+
+func (s *addressStore) DeleteAddress(ctx context.Context, userID, id int) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	a, ok := s.db.addresses[id]
+	if !ok || a.UserID != userID {
+		return store.ErrNotFound
+	}
+	delete(s.db.addresses, id)
+	if a.IsDefault {
+		if next := s.latest(userID); next != nil {
+			next.IsDefault = true
+		}
+	}
+	return nil
+}
+
+func (s *addressStore) SetDefaultAddress(ctx context.Context, userID, id int) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	a, ok := s.db.addresses[id]
+	if !ok || a.UserID != userID {
+		return store.ErrNotFound
+	}
+	s.clearDefault(userID)
+	a.IsDefault = true
+	return nil
+}
+
+// latest 返回用户最近添加的地址，调用方需持有锁
+func (s *addressStore) latest(userID int) *store.Address {
+	var found *store.Address
+	for _, a := range s.db.addresses {
+		if a.UserID == userID && (found == nil || a.ID > found.ID) {
+			found = a
+		}
+	}
+	return found
+}
+
+func (s *addressStore) clearDefault(userID int) {
+	for _, a := range s.db.addresses {
+		if a.UserID == userID {
+			a.IsDefault = false
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package memory
+
+import (
+	"back/store"
+	"context"
+	"sort"
+)
+
+type cartStore struct {
+	db *DB
+}
+
+func (s *cartStore) ListCart(ctx context.Context, userID int) ([]store.CartItem, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	items := []store.CartItem{}
+	for _, row := range s.db.cart {
+		if row.UserID != userID {
+			continue
+		}
+		p, ok := s.db.products[row.ProductID]
+		m, ok2 := s.db.models[row.ModelID]
+		if !ok || !ok2 {
+			continue
+		}
+		it := store.CartItem{
+			ID:        row.ID,
+			ProductID: row.ProductID,
+			ModelID:   row.ModelID,
+			Quantity:  row.Quantity,
+			Title:     p.Title,
+			Category:  p.Category,
+			ModelName: m.Name,
+			Price:     m.Price,
+		}
+		if len(p.Images) > 0 {
+			it.Img = p.Images[0]
+		}
+		items = append(items, it)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID > items[j].ID })
+	return items, nil
+}
+
+func (s *cartStore) AddToCart(ctx context.Context, userID, productID, modelID, qty int) (bool, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	for _, row := range s.db.cart {
+		if row.UserID == userID && row.ProductID == productID && row.ModelID == modelID {
+			row.Quantity += qty
+			return true, nil
+		}
+	}
+	s.db.nextCartID++
+	s.db.cart[s.db.nextCartID] = &cartRow{ID: s.db.nextCartID, UserID: userID, ProductID: productID, ModelID: modelID, Quantity: qty}
+	return false, nil
+}
+
+func (s *cartStore) UpdateCartQuantity(ctx context.Context, userID, cartID, qty int) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	row, ok := s.db.cart[cartID]
+	if !ok || row.UserID != userID {
+		return store.ErrNotFound
+	}
+	row.Quantity = qty
+	return nil
+}
+
+func (s *cartStore) RemoveFromCart(ctx context.Context, userID, cartID int) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if row, ok := s.db.cart[cartID]; ok && row.UserID == userID {
+		delete(s.db.cart, cartID)
+	}
+	return nil
+}
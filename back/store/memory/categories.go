@@ -0,0 +1,33 @@
+package memory
+
+import (
+	"back/store"
+	"context"
+	"sort"
+)
+
+type categoryStore struct {
+	db *DB
+}
+
+func (s *categoryStore) ListCategories(ctx context.Context) ([]store.Category, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	categories := []store.Category{}
+	for _, c := range s.db.categories {
+		cp := *c
+		for _, p := range s.db.products {
+			if p.CategoryID == c.ID {
+				cp.ProductCount++
+			}
+		}
+		categories = append(categories, cp)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if categories[i].SortOrder != categories[j].SortOrder {
+			return categories[i].SortOrder < categories[j].SortOrder
+		}
+		return categories[i].ID < categories[j].ID
+	})
+	return categories, nil
+}
@@ -0,0 +1,71 @@
+package memory
+
+import (
+	"back/store"
+	"context"
+	"sort"
+	"time"
+)
+
+type idempotencyStore struct {
+	db *DB
+}
+
+type idempotencyKey struct {
+	userID int
+	key    string
+}
+
+func (s *idempotencyStore) ReserveIdempotencyKey(ctx context.Context, userID int, key, fingerprint string, expiredBefore, staleBefore time.Time) (*store.IdempotencyRecord, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	k := idempotencyKey{userID, key}
+	if r, ok := s.db.keys[k]; ok && !r.CreatedAt.Before(expiredBefore) && (r.Completed || !r.CreatedAt.Before(staleBefore)) {
+		cp := *r
+		return &cp, nil
+	}
+	s.db.keys[k] = &store.IdempotencyRecord{UserID: userID, Key: key, Fingerprint: fingerprint, CreatedAt: s.db.now()}
+	return nil, nil
+}
+
+func (s *idempotencyStore) CompleteIdempotencyKey(ctx context.Context, userID int, key string, statusCode int, response []byte) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	r, ok := s.db.keys[idempotencyKey{userID, key}]
+	if !ok || r.Completed {
+		return store.ErrNotFound
+	}
+	r.Completed, r.StatusCode, r.Response = true, statusCode, append([]byte(nil), response...)
+	return nil
+}
+
+func (s *idempotencyStore) ReleaseIdempotencyKey(ctx context.Context, userID int, key string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	k := idempotencyKey{userID, key}
+	if r, ok := s.db.keys[k]; ok && !r.Completed {
+		delete(s.db.keys, k)
+	}
+	return nil
+}
+
+func (s *idempotencyStore) DeleteIdempotencyKeysBefore(ctx context.Context, before time.Time, limit int) (int, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	var expired []idempotencyKey
+	for k, r := range s.db.keys {
+		if r.CreatedAt.Before(before) {
+			expired = append(expired, k)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool {
+		return s.db.keys[expired[i]].CreatedAt.Before(s.db.keys[expired[j]].CreatedAt)
+	})
+	if limit > 0 && len(expired) > limit {
+		expired = expired[:limit]
+	}
+	for _, k := range expired {
+		delete(s.db.keys, k)
+	}
+	return len(expired), nil
+}
@@ -0,0 +1,232 @@
+package memory
+
+import (
+	"back/orderstate"
+	"back/store"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DB 内存存储，用于单元测试和无数据库的本地调试。
+// 所有数据由一把互斥锁保护，CreateOrder 等多步操作天然是原子的。
+type DB struct {
+	mu sync.Mutex
+
+	users      map[int]*store.User
+	categories map[int]*store.Category
+	products   map[int]*store.Product
+	models     map[int]*store.Model
+	reviews    map[int]*reviewRow
+	addresses  map[int]*store.Address
+	votes      map[int]map[int]bool // review id -> 投票用户 id
+	cart       map[int]*cartRow
+	orders     map[int]*orderRow
+	payments   map[int]*store.Payment
+	refunds    map[int]*store.Refund
+	events     map[string]bool                             // 已处理的支付通知，key 为渠道 + "\x00" + 通知 ID
+	keys       map[idempotencyKey]*store.IdempotencyRecord // 幂等键
+
+	nextUserID      int
+	nextCartID      int
+	nextOrderID     int
+	nextOrderItemID int
+	nextReviewID    int
+	nextAddressID   int
+	nextPaymentID   int
+	nextRefundID    int
+
+	// now 可在测试中替换以控制时间
+	now func() time.Time
+}
+
+type cartRow struct {
+	ID, UserID, ProductID, ModelID, Quantity int
+}
+
+type reviewRow struct {
+	review store.Review
+	userID int // 评价人，历史评价为 0
+}
+
+type orderRow struct {
+	order   store.Order
+	history []orderstate.HistoryEntry
+}
+
+// New 创建空的内存存储
+func New() *DB {
+	return &DB{
+		users:      map[int]*store.User{},
+		categories: map[int]*store.Category{},
+		products:   map[int]*store.Product{},
+		models:     map[int]*store.Model{},
+		reviews:    map[int]*reviewRow{},
+		addresses:  map[int]*store.Address{},
+		votes:      map[int]map[int]bool{},
+		cart:       map[int]*cartRow{},
+		orders:     map[int]*orderRow{},
+		payments:   map[int]*store.Payment{},
+		refunds:    map[int]*store.Refund{},
+		events:     map[string]bool{},
+		keys:       map[idempotencyKey]*store.IdempotencyRecord{},
+		now:        time.Now,
+	}
+}
+
+// Stores 返回基于该内存数据的全部存储实现
+func (db *DB) Stores() store.Stores {
+	return store.Stores{
+		Users:       &userStore{db},
+		Products:    &productStore{db},
+		Categories:  &categoryStore{db},
+		Reviews:     &reviewStore{db},
+		Addresses:   &addressStore{db},
+		Carts:       &cartStore{db},
+		Orders:      &orderStore{db},
+		Payments:    &paymentStore{db},
+		Refunds:     &refundStore{db},
+		Idempotency: &idempotencyStore{db},
+	}
+}
+
+// SetClock 替换当前时间函数
+func (db *DB) SetClock(now func() time.Time) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.now = now
+}
+
+// AddCategory 写入分类，ID 由调用方指定
+func (db *DB) AddCategory(c store.Category) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	c.ProductCount = 0
+	db.categories[c.ID] = &c
+}
+
+// AddProduct 写入商品及其型号，p.Models 中的 ID 需由调用方指定且全局唯一；
+// p.CategoryID 对应的分类已存在时以分类名称覆盖 p.Category
+func (db *DB) AddProduct(p store.Product) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	cp := p
+	if c, ok := db.categories[p.CategoryID]; ok {
+		cp.Category = c.Name
+	}
+	cp.Images = append([]string{}, p.Images...)
+	cp.Models = nil
+	for _, m := range p.Models {
+		m.ProductID = p.ID
+		mm := m
+		db.models[m.ID] = &mm
+	}
+	db.products[p.ID] = &cp
+}
+
+// AddReview 写入评价并返回评价 ID，r.Status 为空时视为已通过审核
+func (db *DB) AddReview(productID int, r store.Review) int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if r.Status == "" {
+		r.Status = store.ReviewApproved
+	}
+	r.ProductID = productID
+	return db.insertReview(r, 0)
+}
+
+// insertReview 分配 ID 并写入评价，调用方需持有锁
+func (db *DB) insertReview(r store.Review, userID int) int {
+	db.nextReviewID++
+	r.ID = db.nextReviewID
+	r.Images = append([]string{}, r.Images...)
+	db.reviews[r.ID] = &reviewRow{review: r, userID: userID}
+	return r.ID
+}
+
+// ModelStock 返回型号当前库存，型号不存在时返回 -1
+func (db *DB) ModelStock(modelID int) int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if m, ok := db.models[modelID]; ok {
+		return m.Stock
+	}
+	return -1
+}
+
+// productModels 返回商品的型号，按 id 排序，调用方需持有锁
+func (db *DB) productModels(productID int) []store.Model {
+	models := []store.Model{}
+	for _, m := range db.models {
+		if m.ProductID == productID {
+			models = append(models, *m)
+		}
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].ID < models[j].ID })
+	return models
+}
+
+// productSummary 汇总商品列表项，调用方需持有锁
+func (db *DB) productSummary(p *store.Product) store.ProductSummary {
+	sum := store.ProductSummary{ID: p.ID, Title: p.Title, CategoryID: p.CategoryID, Category: p.Category, ProductStats: db.productStats(p.ID)}
+	if len(p.Images) > 0 {
+		sum.Img = p.Images[0]
+	}
+	for i, m := range db.productModels(p.ID) {
+		if i == 0 || m.Price < sum.MinPrice {
+			sum.MinPrice = m.Price
+		}
+		sum.Stock += m.Stock
+	}
+	return sum
+}
+
+// productStats 由订单和评价即时计算商品统计（postgres 实现中由 product_stats 表维护），调用方需持有锁
+func (db *DB) productStats(productID int) store.ProductStats {
+	var st store.ProductStats
+	for _, row := range db.orders {
+		if !orderstate.CountsAsSale(orderstate.Status(row.order.Status)) {
+			continue
+		}
+		for _, it := range row.order.Items {
+			if it.ProductID == productID && !db.itemRefunded(it.ID) {
+				st.Sales += it.Quantity
+			}
+		}
+	}
+	total := 0
+	for _, row := range db.reviews {
+		if row.review.ProductID == productID && row.review.Status == store.ReviewApproved {
+			st.ReviewCount++
+			total += row.review.Rating
+		}
+	}
+	if st.ReviewCount > 0 {
+		st.Rating = math.Round(float64(total)/float64(st.ReviewCount)*100) / 100
+	}
+	return st
+}
+
+// inCategories 判断商品是否属于 ids 中的分类，ids 为 nil 表示不限制
+func inCategories(p *store.Product, ids []int) bool {
+	if ids == nil {
+		return true
+	}
+	for _, id := range ids {
+		if p.CategoryID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// itemReviewed 判断订单明细是否已评价，调用方需持有锁
+func (db *DB) itemReviewed(orderItemID int) bool {
+	for _, row := range db.reviews {
+		if row.review.OrderItemID == orderItemID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,230 @@
+package memory
+
+import (
+	"back/orderstate"
+	"back/store"
+	"context"
+	"sort"
+	"time"
+)
+
+type orderStore struct {
+	db *DB
+}
+
+func (s *orderStore) CountOrdersByStatus(ctx context.Context, userID int) (map[orderstate.Status]int, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	counts := map[orderstate.Status]int{}
+	for _, row := range s.db.orders {
+		if row.order.UserID == userID {
+			counts[row.order.Status]++
+		}
+	}
+	return counts, nil
+}
+
+func (s *orderStore) ListOrders(ctx context.Context, userID int, status orderstate.Status) ([]store.Order, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	orders := []store.Order{}
+	for _, row := range s.db.orders {
+		if row.order.UserID != userID || (status != "" && row.order.Status != status) {
+			continue
+		}
+		o := row.order
+		o.ItemCount = len(o.Items)
+		o.Items = nil
+		o.Shipping = store.ShippingAddress{}
+		orders = append(orders, o)
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].CreatedAt.After(orders[j].CreatedAt) })
+	return orders, nil
+}
+
+func (s *orderStore) GetOrder(ctx context.Context, userID, orderID int) (*store.Order, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	row, ok := s.db.orders[orderID]
+	if !ok || row.order.UserID != userID {
+		return nil, store.ErrNotFound
+	}
+	o := row.order
+	o.Items = append([]store.OrderItem{}, row.order.Items...)
+	for i := range o.Items {
+		o.Items[i].Reviewed = s.db.itemReviewed(o.Items[i].ID)
+		o.Items[i].RefundStatus = s.db.refundStatus(o.Items[i].ID)
+	}
+	o.ItemCount = len(o.Items)
+	return &o, nil
+}
+
+func (s *orderStore) OrderHistory(ctx context.Context, orderID int) ([]orderstate.HistoryEntry, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	row, ok := s.db.orders[orderID]
+	if !ok {
+		return []orderstate.HistoryEntry{}, nil
+	}
+	return append([]orderstate.HistoryEntry{}, row.history...), nil
+}
+
+func (s *orderStore) CreateOrder(ctx context.Context, userID int, ship store.ShippingAddress, actor string, modelIDs []int, build store.OrderBuilder) (int, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	models := map[int]store.Model{}
+	for _, id := range modelIDs {
+		if m, ok := s.db.models[id]; ok {
+			models[id] = *m
+		}
+	}
+	order, err := build(models)
+	if err != nil {
+		return 0, err
+	}
+	need := map[int]int{}
+	for _, it := range order.Items {
+		need[it.ModelID] += it.Quantity
+	}
+	for id, q := range need {
+		m, ok := s.db.models[id]
+		if !ok || m.Stock < q {
+			return 0, store.ErrConflict
+		}
+	}
+	for id, q := range need {
+		s.db.models[id].Stock -= q
+	}
+	now := s.db.now()
+	s.db.nextOrderID++
+	items := append([]store.OrderItem{}, order.Items...)
+	for i := range items {
+		s.db.nextOrderItemID++
+		items[i].ID = s.db.nextOrderItemID
+		if p, ok := s.db.products[items[i].ProductID]; ok {
+			items[i].Title = p.Title
+			if len(p.Images) > 0 {
+				items[i].Image = p.Images[0]
+			}
+		}
+		items[i].ModelName = s.db.models[items[i].ModelID].Name
+	}
+	row := &orderRow{
+		order: store.Order{
+			ID:         s.db.nextOrderID,
+			UserID:     userID,
+			Status:     orderstate.Pending,
+			TotalPrice: order.Total,
+			Address:    ship.String(),
+			Shipping:   ship,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+			Items:      items,
+		},
+		history: []orderstate.HistoryEntry{{To: string(orderstate.Pending), Actor: actor, CreatedAt: now}},
+	}
+	s.db.orders[row.order.ID] = row
+	return row.order.ID, nil
+}
+
+func (s *orderStore) TransitionOrder(ctx context.Context, orderID int, to orderstate.Status, actor, note string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	_, err := s.transition(orderID, to, actor, note)
+	return err
+}
+
+func (s *orderStore) CancelOrder(ctx context.Context, orderID int, actor, reason string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	row, err := s.transition(orderID, orderstate.Cancelled, actor, reason)
+	if err != nil {
+		return err
+	}
+	for _, it := range row.order.Items {
+		if m, ok := s.db.models[it.ModelID]; ok {
+			m.Stock += it.Quantity
+		}
+	}
+	row.order.CancelReason = reason
+	return nil
+}
+
+func (s *orderStore) ListPendingOrdersBefore(ctx context.Context, before time.Time, limit int) ([]int, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	var ids []int
+	for id, row := range s.db.orders {
+		if row.order.Status == orderstate.Pending && row.order.CreatedAt.Before(before) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	if limit > 0 && len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids, nil
+}
+
+func (s *orderStore) ReviewOrderItem(ctx context.Context, orderID int, r store.NewReview, actor string) (bool, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	row, ok := s.db.orders[orderID]
+	if !ok || row.order.UserID != r.UserID {
+		return false, store.ErrNotFound
+	}
+	var item *store.OrderItem
+	for i := range row.order.Items {
+		if row.order.Items[i].ID == r.OrderItemID {
+			item = &row.order.Items[i]
+		}
+	}
+	if item == nil || s.db.itemRefunded(item.ID) {
+		return false, store.ErrNotFound
+	}
+	if row.order.Status != orderstate.ToReview {
+		return false, store.ErrInvalidState
+	}
+	if s.db.itemReviewed(item.ID) {
+		return false, store.ErrConflict
+	}
+	s.db.insertReview(store.Review{
+		ProductID:   item.ProductID,
+		Username:    r.Username,
+		Rating:      r.Rating,
+		Content:     r.Content,
+		CreatedAt:   s.db.now(),
+		ModelID:     item.ModelID,
+		OrderItemID: item.ID,
+		Status:      r.Status,
+		Images:      r.Images,
+	}, r.UserID)
+	for _, it := range row.order.Items {
+		if !s.db.itemReviewed(it.ID) && !s.db.itemRefunded(it.ID) {
+			return false, nil
+		}
+	}
+	if _, err := s.transition(orderID, orderstate.Completed, actor, "全部商品已评价"); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// transition 校验并变更状态，调用方需持有锁
+func (s *orderStore) transition(orderID int, to orderstate.Status, actor, note string) (*orderRow, error) {
+	row, ok := s.db.orders[orderID]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	from := row.order.Status
+	if err := orderstate.Orders.Check(from, to); err != nil {
+		return nil, err
+	}
+	now := s.db.now()
+	row.order.Status = to
+	row.order.UpdatedAt = now
+	row.history = append(row.history, orderstate.HistoryEntry{
+		From: string(from), To: string(to), Actor: actor, Note: note, CreatedAt: now,
+	})
+	return row, nil
+}
@@ -0,0 +1,172 @@
+package memory
+
+import (
+	"back/orderstate"
+	"back/payment"
+	"back/store"
+	"context"
+	"errors"
+	"sort"
+)
+
+type paymentStore struct {
+	db *DB
+}
+
+func (s *paymentStore) CreatePayment(ctx context.Context, p *store.Payment) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if _, ok := s.db.orders[p.OrderID]; !ok {
+		return store.ErrNotFound
+	}
+	for _, existing := range s.db.payments {
+		if existing.OrderID == p.OrderID && existing.Status == payment.StatusPending {
+			return store.ErrConflict
+		}
+		if existing.Provider == p.Provider && existing.TransactionID == p.TransactionID {
+			return store.ErrConflict
+		}
+	}
+	now := s.db.now()
+	s.db.nextPaymentID++
+	p.ID = s.db.nextPaymentID
+	p.Status = payment.StatusPending
+	p.CreatedAt, p.UpdatedAt = now, now
+	cp := *p
+	s.db.payments[p.ID] = &cp
+	return nil
+}
+
+func (s *paymentStore) GetPayment(ctx context.Context, id int) (*store.Payment, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	p, ok := s.db.payments[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	cp := *p
+	return &cp, nil
+}
+
+func (s *paymentStore) LatestPayment(ctx context.Context, orderID int) (*store.Payment, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	var latest *store.Payment
+	for _, p := range s.db.payments {
+		if p.OrderID == orderID && (latest == nil || p.ID > latest.ID) {
+			latest = p
+		}
+	}
+	if latest == nil {
+		return nil, store.ErrNotFound
+	}
+	cp := *latest
+	return &cp, nil
+}
+
+func (s *paymentStore) OrderPayment(ctx context.Context, orderID int) (*store.Payment, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	var paid *store.Payment
+	for _, p := range s.db.payments {
+		if p.OrderID == orderID && p.Status == payment.StatusSucceeded && !p.NeedsRefund && (paid == nil || p.ID < paid.ID) {
+			paid = p
+		}
+	}
+	if paid == nil {
+		return nil, store.ErrNotFound
+	}
+	cp := *paid
+	return &cp, nil
+}
+
+func (s *paymentStore) SettlePayment(ctx context.Context, id int, status payment.Status, actor string) (bool, error) {
+	if !status.Final() {
+		return false, store.ErrInvalidState
+	}
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	p, ok := s.db.payments[id]
+	if !ok {
+		return false, store.ErrNotFound
+	}
+	if p.Status != payment.StatusPending {
+		return false, store.ErrInvalidState
+	}
+	return s.settle(p, status, actor)
+}
+
+func (s *paymentStore) ApplyPaymentEvent(ctx context.Context, e store.PaymentEvent, actor string) (bool, error) {
+	if !e.Status.Final() {
+		return false, store.ErrInvalidState
+	}
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	var p *store.Payment
+	for _, row := range s.db.payments {
+		if row.Provider == e.Provider && row.TransactionID == e.TransactionID {
+			p = row
+		}
+	}
+	if p == nil {
+		return false, store.ErrNotFound
+	}
+	key := e.Provider + "\x00" + e.EventID
+	if s.db.events[key] {
+		return false, store.ErrConflict
+	}
+	s.db.events[key] = true
+	// 已记为失败的支付收到成功通知时以通知为准重新结算，见 postgres 实现的 lateSuccess
+	lateSuccess := p.Status == payment.StatusFailed && e.Status == payment.StatusSucceeded
+	if p.Status != payment.StatusPending && !lateSuccess {
+		return false, nil
+	}
+	return s.settle(p, e.Status, actor)
+}
+
+// settle 把待支付（或已失败但收到成功通知的）记录更新为最终状态，支付成功且订单仍待付款时流转为待发货，调用方需持有锁
+func (s *paymentStore) settle(p *store.Payment, status payment.Status, actor string) (bool, error) {
+	p.Status = status
+	p.UpdatedAt = s.db.now()
+	if status != payment.StatusSucceeded {
+		return false, nil
+	}
+	orders := &orderStore{s.db}
+	_, err := orders.transition(p.OrderID, orderstate.ToShip, actor, "支付成功")
+	var transErr *orderstate.TransitionError
+	if errors.As(err, &transErr) {
+		p.NeedsRefund = true
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *paymentStore) ListPaymentsToRefund(ctx context.Context, limit int) ([]store.Payment, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	list := []store.Payment{}
+	for _, p := range s.db.payments {
+		if p.NeedsRefund && !p.Refunded {
+			list = append(list, *p)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list, nil
+}
+
+func (s *paymentStore) MarkPaymentRefunded(ctx context.Context, id int, providerRefundID string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	p, ok := s.db.payments[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if !p.NeedsRefund || p.Refunded {
+		return store.ErrInvalidState
+	}
+	p.Refunded, p.UpdatedAt = true, s.db.now()
+	return nil
+}
@@ -0,0 +1,122 @@
+package memory
+
+import (
+	"back/store"
+	"context"
+	"sort"
+	"strings"
+)
+
+type productStore struct {
+	db *DB
+}
+
+func (s *productStore) ListProducts(ctx context.Context, q store.ProductQuery) (*store.ProductPage, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	filtered := []store.ProductSummary{}
+	for _, p := range s.db.products {
+		if !inCategories(p, q.CategoryIDs) {
+			continue
+		}
+		sum := s.db.productSummary(p)
+		matched := false
+		for _, m := range s.db.productModels(p.ID) {
+			if (q.MinPrice != nil && m.Price < *q.MinPrice) || (q.MaxPrice != nil && m.Price > *q.MaxPrice) || (q.InStock && m.Stock <= 0) {
+				continue
+			}
+			if !matched || m.Price < sum.MinPrice {
+				sum.MinPrice = m.Price
+			}
+			matched = true
+		}
+		if !matched && (q.MinPrice != nil || q.MaxPrice != nil || q.InStock) {
+			continue
+		}
+		filtered = append(filtered, sum)
+	}
+	// before 判断 a 是否排在 b 之前
+	before := func(aKey float64, aID int, bKey float64, bID int) bool {
+		if aKey != bKey {
+			return (aKey < bKey) == q.Sort.Ascending()
+		}
+		return aID != bID && (aID < bID) == q.Sort.Ascending()
+	}
+	sort.Slice(filtered, func(i, j int) bool {
+		return before(q.Sort.Key(filtered[i]), filtered[i].ID, q.Sort.Key(filtered[j]), filtered[j].ID)
+	})
+	page := &store.ProductPage{Items: []store.ProductSummary{}, Total: len(filtered)}
+	for _, p := range filtered {
+		if q.After != nil && !before(q.After.Key, q.After.ID, q.Sort.Key(p), p.ID) {
+			continue
+		}
+		if len(page.Items) == q.Limit {
+			last := page.Items[q.Limit-1]
+			page.Next = &store.ProductCursor{Key: q.Sort.Key(last), ID: last.ID}
+			break
+		}
+		page.Items = append(page.Items, p)
+	}
+	return page, nil
+}
+
+// SearchProducts 只做大小写不敏感的子串匹配，相关度规则与 postgres 实现一致（不含相似度）
+func (s *productStore) SearchProducts(ctx context.Context, q store.ProductSearch) ([]store.ProductSearchResult, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	results := []store.ProductSearchResult{}
+	for _, p := range s.db.products {
+		if !inCategories(p, q.CategoryIDs) {
+			continue
+		}
+		title, desc := strings.ToLower(p.Title), strings.ToLower(p.Description)
+		rank, matched := 0.0, true
+		for _, t := range q.Terms {
+			t = strings.ToLower(t)
+			inTitle, inDesc := strings.Contains(title, t), strings.Contains(desc, t)
+			if !inTitle && !inDesc {
+				matched = false
+				break
+			}
+			if inTitle {
+				rank += 3
+			}
+			if inDesc {
+				rank += 1
+			}
+		}
+		if !matched {
+			continue
+		}
+		r := store.ProductSearchResult{
+			ProductSummary: s.db.productSummary(p),
+			Description:    p.Description,
+			Rank:           rank,
+		}
+		results = append(results, r)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].ID > results[j].ID
+	})
+	if q.Limit > 0 && len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+	return results, nil
+}
+
+func (s *productStore) GetProduct(ctx context.Context, id int) (*store.Product, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	p, ok := s.db.products[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	cp := *p
+	cp.Images = append([]string{}, p.Images...)
+	cp.Models = s.db.productModels(id)
+	cp.ProductStats = s.db.productStats(id)
+	return &cp, nil
+}
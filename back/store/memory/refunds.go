@@ -0,0 +1,215 @@
+package memory
+
+import (
+	"back/orderstate"
+	"back/store"
+	"context"
+	"sort"
+)
+
+type refundStore struct {
+	db *DB
+}
+
+func (s *refundStore) CreateRefund(ctx context.Context, r *store.Refund, allowed []orderstate.Status) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	row, ok := s.db.orders[r.OrderID]
+	if !ok || row.order.UserID != r.UserID {
+		return store.ErrNotFound
+	}
+	var item *store.OrderItem
+	for i := range row.order.Items {
+		if row.order.Items[i].ID == r.OrderItemID {
+			item = &row.order.Items[i]
+		}
+	}
+	if item == nil || item.ProductID == 0 || item.ModelID == 0 {
+		return store.ErrNotFound
+	}
+	if !containsStatus(allowed, row.order.Status) {
+		return store.ErrInvalidState
+	}
+	for _, existing := range s.db.refunds {
+		if existing.OrderItemID == item.ID && existing.Status != store.RefundRejected {
+			return store.ErrConflict
+		}
+	}
+	now := s.db.now()
+	s.db.nextRefundID++
+	r.ID = s.db.nextRefundID
+	r.ImageKeys = append([]string{}, r.ImageKeys...)
+	r.Amount = item.Price * float64(item.Quantity)
+	r.Status = store.RefundRequested
+	r.ReviewedBy, r.ReviewNote, r.ProviderRefundID = "", "", ""
+	r.CreatedAt, r.UpdatedAt = now, now
+	r.Title, r.ModelName, r.Quantity = item.Title, item.ModelName, item.Quantity
+	cp := *r
+	s.db.refunds[r.ID] = &cp
+	return nil
+}
+
+func (s *refundStore) GetRefund(ctx context.Context, id int) (*store.Refund, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	r, ok := s.db.refunds[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return copyRefund(r), nil
+}
+
+func (s *refundStore) ListRefunds(ctx context.Context, q store.RefundQuery) (*store.RefundPage, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	items := []store.Refund{}
+	for _, r := range s.db.refunds {
+		if (q.UserID > 0 && r.UserID != q.UserID) || (q.OrderID > 0 && r.OrderID != q.OrderID) ||
+			(q.Status != "" && r.Status != q.Status) {
+			continue
+		}
+		items = append(items, *copyRefund(r))
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if q.Oldest {
+			return items[i].ID < items[j].ID
+		}
+		return items[i].ID > items[j].ID
+	})
+	page := &store.RefundPage{Total: len(items)}
+	if q.Offset > len(items) {
+		q.Offset = len(items)
+	}
+	items = items[q.Offset:]
+	if q.Limit > 0 && len(items) > q.Limit {
+		items = items[:q.Limit]
+	}
+	page.Items = items
+	return page, nil
+}
+
+func (s *refundStore) RejectRefund(ctx context.Context, id int, actor, note string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	r, ok := s.db.refunds[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if r.Status != store.RefundRequested {
+		return store.ErrInvalidState
+	}
+	r.Status, r.ReviewedBy, r.ReviewNote, r.UpdatedAt = store.RefundRejected, actor, note, s.db.now()
+	return nil
+}
+
+func (s *refundStore) ApproveRefund(ctx context.Context, id int, actor, note string) (*store.Refund, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	r, ok := s.db.refunds[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	switch r.Status {
+	case store.RefundApproved:
+	case store.RefundRequested:
+		r.Status, r.ReviewedBy, r.ReviewNote, r.UpdatedAt = store.RefundApproved, actor, note, s.db.now()
+		// 已发货后的仅退款商品留在用户手中，不归还库存
+		row := s.db.orders[r.OrderID]
+		if r.Type == store.RefundReturn || row.order.Status == orderstate.ToShip {
+			if it := s.db.orderItem(r.OrderID, r.OrderItemID); it != nil {
+				if m, ok := s.db.models[it.ModelID]; ok {
+					m.Stock += it.Quantity
+				}
+			}
+		}
+	default:
+		return nil, store.ErrInvalidState
+	}
+	return copyRefund(r), nil
+}
+
+func (s *refundStore) CompleteRefund(ctx context.Context, id int, providerRefundID, actor string) (bool, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	r, ok := s.db.refunds[id]
+	if !ok {
+		return false, store.ErrNotFound
+	}
+	if r.Status != store.RefundApproved {
+		return false, store.ErrInvalidState
+	}
+	r.Status, r.ProviderRefundID, r.UpdatedAt = store.RefundCompleted, providerRefundID, s.db.now()
+	row := s.db.orders[r.OrderID]
+	remaining, unreviewed := 0, 0
+	for _, it := range row.order.Items {
+		if s.db.itemRefunded(it.ID) {
+			continue
+		}
+		remaining++
+		if !s.db.itemReviewed(it.ID) {
+			unreviewed++
+		}
+	}
+	orders := &orderStore{s.db}
+	// 已完成的订单不再流转到退款状态
+	refunded := remaining == 0 && orderstate.Orders.Check(row.order.Status, orderstate.Refund) == nil
+	var err error
+	switch {
+	case refunded:
+		_, err = orders.transition(r.OrderID, orderstate.Refund, actor, "全部商品已退款")
+	case row.order.Status == orderstate.ToReview && unreviewed == 0:
+		_, err = orders.transition(r.OrderID, orderstate.Completed, actor, "全部商品已评价")
+	}
+	return refunded, err
+}
+
+// orderItem 查找订单明细，调用方需持有锁
+func (db *DB) orderItem(orderID, orderItemID int) *store.OrderItem {
+	row, ok := db.orders[orderID]
+	if !ok {
+		return nil
+	}
+	for i := range row.order.Items {
+		if row.order.Items[i].ID == orderItemID {
+			return &row.order.Items[i]
+		}
+	}
+	return nil
+}
+
+// refundStatus 订单明细最近一次退款申请的状态，调用方需持有锁
+func (db *DB) refundStatus(orderItemID int) store.RefundStatus {
+	latest := 0
+	var status store.RefundStatus
+	for id, r := range db.refunds {
+		if r.OrderItemID == orderItemID && id > latest {
+			latest, status = id, r.Status
+		}
+	}
+	return status
+}
+
+// itemRefunded 判断订单明细是否已退款，调用方需持有锁
+func (db *DB) itemRefunded(orderItemID int) bool {
+	for _, r := range db.refunds {
+		if r.OrderItemID == orderItemID && r.Status == store.RefundCompleted {
+			return true
+		}
+	}
+	return false
+}
+
+func copyRefund(r *store.Refund) *store.Refund {
+	cp := *r
+	cp.ImageKeys = append([]string{}, r.ImageKeys...)
+	return &cp
+}
+
+func containsStatus(list []orderstate.Status, s orderstate.Status) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
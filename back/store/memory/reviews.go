@@ -0,0 +1,92 @@
+package memory
+
+import (
+	"back/store"
+	"context"
+	"sort"
+)
+
+type reviewStore struct {
+	db *DB
+}
+
+func (s *reviewStore) ListReviews(ctx context.Context, q store.ReviewQuery) (*store.ReviewPage, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	reviews := []store.Review{}
+	for _, row := range s.db.reviews {
+		r := row.review
+		if (q.ProductID > 0 && r.ProductID != q.ProductID) || (q.Status != "" && r.Status != q.Status) || (q.Rating > 0 && r.Rating != q.Rating) {
+			continue
+		}
+		r.Images = append([]string{}, r.Images...)
+		reviews = append(reviews, r)
+	}
+	newer := func(a, b store.Review) bool {
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ID > b.ID
+	}
+	sort.Slice(reviews, func(i, j int) bool {
+		a, b := reviews[i], reviews[j]
+		switch q.Sort {
+		case store.ReviewSortHelpful:
+			if a.HelpfulCount != b.HelpfulCount {
+				return a.HelpfulCount > b.HelpfulCount
+			}
+		case store.ReviewSortOldest:
+			return newer(b, a)
+		}
+		return newer(a, b)
+	})
+	page := &store.ReviewPage{Total: len(reviews)}
+	if q.Offset > len(reviews) {
+		q.Offset = len(reviews)
+	}
+	reviews = reviews[q.Offset:]
+	if q.Limit > 0 && len(reviews) > q.Limit {
+		reviews = reviews[:q.Limit]
+	}
+	page.Items = reviews
+	return page, nil
+}
+
+func (s *reviewStore) SetReviewStatus(ctx context.Context, reviewID int, status store.ReviewStatus, actor string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	row, ok := s.db.reviews[reviewID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	// 商品评分由 productStats 按 approved 评价即时计算，无需同步
+	row.review.Status = status
+	return nil
+}
+
+func (s *reviewStore) VoteHelpful(ctx context.Context, reviewID, userID int, helpful bool) (int, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	row, ok := s.db.reviews[reviewID]
+	if !ok || row.review.Status != store.ReviewApproved {
+		return 0, store.ErrNotFound
+	}
+	if row.userID == userID {
+		return 0, store.ErrInvalidState
+	}
+	voters := s.db.votes[reviewID]
+	if voters == nil {
+		voters = map[int]bool{}
+		s.db.votes[reviewID] = voters
+	}
+	if voters[userID] != helpful {
+		if helpful {
+			voters[userID] = true
+			row.review.HelpfulCount++
+		} else {
+			delete(voters, userID)
+			row.review.HelpfulCount--
+		}
+	}
+	return row.review.HelpfulCount, nil
+}
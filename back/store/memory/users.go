@@ -0,0 +1,82 @@
+package memory
+
+import (
+	"back/store"
+	"context"
+)
+
+type userStore struct {
+	db *DB
+}
+
+func (s *userStore) GetUser(ctx context.Context, id int) (*store.User, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	u, ok := s.db.users[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	cp := *u
+	return &cp, nil
+}
+
+func (s *userStore) GetUserByUsername(ctx context.Context, username string) (*store.User, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	for _, u := range s.db.users {
+		if u.Username == username {
+			cp := *u
+			return &cp, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *userStore) CreateUser(ctx context.Context, u *store.User) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	for _, existing := range s.db.users {
+		if existing.Username == u.Username {
+			return store.ErrConflict
+		}
+	}
+	s.db.nextUserID++
+	u.ID = s.db.nextUserID
+	if u.Role == "" {
+		u.Role = store.RoleUser
+	}
+	cp := *u
+	s.db.users[u.ID] = &cp
+	return nil
+}
+
+func (s *userStore) UpdatePassword(ctx context.Context, id int, oldHash, newHash string) error {
+	return s.update(id, func(u *store.User) {
+		if u.PasswordHash == oldHash {
+			u.PasswordHash = newHash
+		}
+	})
+}
+
+func (s *userStore) UpdateAddress(ctx context.Context, id int, address string) error {
+	return s.update(id, func(u *store.User) { u.Address = address })
+}
+
+func (s *userStore) UpdateNickname(ctx context.Context, id int, nickname string) error {
+	return s.update(id, func(u *store.User) { u.Nickname = nickname })
+}
+
+func (s *userStore) UpdateAvatar(ctx context.Context, id int, avatar string) (string, error) {
+	var old string
+	err := s.update(id, func(u *store.User) { old, u.Avatar = u.Avatar, avatar })
+	return old, err
+}
+
+func (s *userStore) update(id int, fn func(u *store.User)) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if u, ok := s.db.users[id]; ok {
+		fn(u)
+	}
+	return nil
+}
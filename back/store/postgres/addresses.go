@@ -0,0 +1,150 @@
+package postgres
+
+import (
+	"back/store"
+	"context"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type addressStore struct {
+	pool *pgxpool.Pool
+}
+
+const addressColumns = "id, user_id, recipient, phone, province, city, district, detail, postal_code, is_default"
+
+func scanAddress(row pgx.Row) (*store.Address, error) {
+	a := &store.Address{}
+	err := row.Scan(&a.ID, &a.UserID, &a.Recipient, &a.Phone, &a.Province, &a.City, &a.District, &a.Detail, &a.PostalCode, &a.IsDefault)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (s *addressStore) ListAddresses(ctx context.Context, userID int) ([]store.Address, error) {
+	rows, err := s.pool.Query(ctx,
+		"SELECT "+addressColumns+" FROM user_addresses WHERE user_id=$1 ORDER BY is_default DESC, id DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []store.Address{}
+	for rows.Next() {
+		a, err := scanAddress(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *a)
+	}
+	return list, rows.Err()
+}
+
+func (s *addressStore) GetAddress(ctx context.Context, userID, id int) (*store.Address, error) {
+	a, err := scanAddress(s.pool.QueryRow(ctx,
+		"SELECT "+addressColumns+" FROM user_addresses WHERE id=$1 AND user_id=$2", id, userID))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return a, nil
+}
+
+func (s *addressStore) CreateAddress(ctx context.Context, a *store.Address, limit int) error {
+	return s.inUserTx(ctx, a.UserID, func(tx pgx.Tx) error {
+		var count int
+		if err := tx.QueryRow(ctx, "SELECT COUNT(*) FROM user_addresses WHERE user_id=$1", a.UserID).Scan(&count); err != nil {
+			return err
+		}
+		if count >= limit {
+			return store.ErrLimitExceeded
+		}
+		// 第一个地址自动成为默认
+		if count == 0 {
+			a.IsDefault = true
+		}
+		if a.IsDefault {
+			if err := clearDefault(ctx, tx, a.UserID); err != nil {
+				return err
+			}
+		}
+		return tx.QueryRow(ctx,
+			`INSERT INTO user_addresses (user_id, recipient, phone, province, city, district, detail, postal_code, is_default)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`,
+			a.UserID, a.Recipient, a.Phone, a.Province, a.City, a.District, a.Detail, a.PostalCode, a.IsDefault).Scan(&a.ID)
+	})
+}
+
+func (s *addressStore) UpdateAddress(ctx context.Context, a *store.Address) error {
+	return s.inUserTx(ctx, a.UserID, func(tx pgx.Tx) error {
+		if a.IsDefault {
+			if err := clearDefault(ctx, tx, a.UserID); err != nil {
+				return err
+			}
+		}
+		err := tx.QueryRow(ctx,
+			`UPDATE user_addresses SET recipient=$3, phone=$4, province=$5, city=$6, district=$7, detail=$8,
+				postal_code=$9, is_default = is_default OR $10, updated_at=NOW()
+			 WHERE id=$1 AND user_id=$2 RETURNING is_default`,
+			a.ID, a.UserID, a.Recipient, a.Phone, a.Province, a.City, a.District, a.Detail, a.PostalCode, a.IsDefault).Scan(&a.IsDefault)
+		return notFound(err)
+	})
+}
+
+func (s *addressStore) DeleteAddress(ctx context.Context, userID, id int) error {
+	return s.inUserTx(ctx, userID, func(tx pgx.Tx) error {
+		var wasDefault bool
+		err := tx.QueryRow(ctx, "DELETE FROM user_addresses WHERE id=$1 AND user_id=$2 RETURNING is_default", id, userID).Scan(&wasDefault)
+		if err != nil {
+			return notFound(err)
+		}
+		if !wasDefault {
+			return nil
+		}
+		_, err = tx.Exec(ctx,
+			`UPDATE user_addresses SET is_default = true, updated_at=NOW()
+			 WHERE id = (SELECT id FROM user_addresses WHERE user_id=$1 ORDER BY id DESC LIMIT 1)`, userID)
+		return err
+	})
+}
+
+func (s *addressStore) SetDefaultAddress(ctx context.Context, userID, id int) error {
+	return s.inUserTx(ctx, userID, func(tx pgx.Tx) error {
+		var exists bool
+		if err := tx.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM user_addresses WHERE id=$1 AND user_id=$2)", id, userID).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return store.ErrNotFound
+		}
+		if err := clearDefault(ctx, tx, userID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, "UPDATE user_addresses SET is_default = true, updated_at=NOW() WHERE id=$1", id)
+		return err
+	})
+}
+
+// inUserTx 在事务中执行 fn，事务开始时锁定用户行，同一用户的地址修改串行执行，
+// 避免并发设置默认地址时违反 uq_user_addresses_default
+func (s *addressStore) inUserTx(ctx context.Context, userID int, fn func(tx pgx.Tx) error) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	var id int
+	if err := tx.QueryRow(ctx, "SELECT id FROM users WHERE id=$1 FOR UPDATE", userID).Scan(&id); err != nil {
+		return notFound(err)
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// clearDefault 取消用户的默认地址
+func clearDefault(ctx context.Context, tx pgx.Tx, userID int) error {
+	_, err := tx.Exec(ctx, "UPDATE user_addresses SET is_default = false WHERE user_id=$1 AND is_default", userID)
+	return err
+}
@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"back/store"
+	"context"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type cartStore struct {
+	pool *pgxpool.Pool
+}
+
+func (s *cartStore) ListCart(ctx context.Context, userID int) ([]store.CartItem, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT c.id, c.product_id, c.model_id, c.quantity,
+		       p.title, COALESCE(cat.name,''),
+		       m.model_name, m.price,
+		       COALESCE(img.url, '') AS img_url
+		FROM cart c
+		JOIN products p ON c.product_id = p.id
+		LEFT JOIN categories cat ON cat.id = p.category_id
+		JOIN product_models m ON c.model_id = m.id
+		LEFT JOIN LATERAL (
+			SELECT url FROM product_images
+			WHERE product_id = p.id
+			ORDER BY id LIMIT 1
+		) img ON TRUE
+		WHERE c.user_id = $1
+		ORDER BY c.id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []store.CartItem{}
+	for rows.Next() {
+		var it store.CartItem
+		err := rows.Scan(&it.ID, &it.ProductID, &it.ModelID, &it.Quantity, &it.Title, &it.Category, &it.ModelName, &it.Price, &it.Img)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+func (s *cartStore) AddToCart(ctx context.Context, userID, productID, modelID, qty int) (bool, error) {
+	// 已存在该商品型号则累加数量，依赖 (user_id, product_id, model_id) 唯一约束
+	var inserted bool
+	err := s.pool.QueryRow(ctx,
+		`INSERT INTO cart (user_id, product_id, model_id, quantity) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (user_id, product_id, model_id)
+		 DO UPDATE SET quantity = cart.quantity + EXCLUDED.quantity, updated_at = now()
+		 RETURNING (xmax = 0)`,
+		userID, productID, modelID, qty).Scan(&inserted)
+	if err != nil {
+		return false, err
+	}
+	return !inserted, nil
+}
+
+func (s *cartStore) UpdateCartQuantity(ctx context.Context, userID, cartID, qty int) error {
+	tag, err := s.pool.Exec(ctx, "UPDATE cart SET quantity=$1, updated_at=now() WHERE id=$2 AND user_id=$3", qty, cartID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *cartStore) RemoveFromCart(ctx context.Context, userID, cartID int) error {
+	_, err := s.pool.Exec(ctx, "DELETE FROM cart WHERE id=$1 AND user_id=$2", cartID, userID)
+	return err
+}
@@ -0,0 +1,33 @@
+package postgres
+
+import (
+	"back/store"
+	"context"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type categoryStore struct {
+	pool *pgxpool.Pool
+}
+
+func (s *categoryStore) ListCategories(ctx context.Context) ([]store.Category, error) {
+	rows, err := s.pool.Query(ctx, `SELECT c.id, COALESCE(c.parent_id,0), c.name, c.slug, c.sort_order, COUNT(p.id)
+		FROM categories c
+		LEFT JOIN products p ON p.category_id = c.id
+		GROUP BY c.id
+		ORDER BY c.sort_order, c.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	categories := []store.Category{}
+	for rows.Next() {
+		var c store.Category
+		if err := rows.Scan(&c.ID, &c.ParentID, &c.Name, &c.Slug, &c.SortOrder, &c.ProductCount); err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
@@ -0,0 +1,81 @@
+package postgres
+
+import (
+	"back/store"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type idempotencyStore struct {
+	pool *pgxpool.Pool
+}
+
+func (s *idempotencyStore) ReserveIdempotencyKey(ctx context.Context, userID int, key, fingerprint string, expiredBefore, staleBefore time.Time) (*store.IdempotencyRecord, error) {
+	// 不存在时写入；已存在但过期，或处理中的记录超过占用时限时原地改为新的处理中记录；
+	// 并发的同一 key 在唯一索引上等待，之后读到已有记录
+	var reserved bool
+	err := s.pool.QueryRow(ctx,
+		`INSERT INTO idempotency_keys (user_id, idempotency_key, fingerprint, created_at) VALUES ($1, $2, $3, NOW())
+		 ON CONFLICT (user_id, idempotency_key) DO UPDATE
+		 SET fingerprint = EXCLUDED.fingerprint, status_code = NULL, response = NULL, created_at = NOW(), completed_at = NULL
+		 WHERE idempotency_keys.created_at < $4 OR (idempotency_keys.status_code IS NULL AND idempotency_keys.created_at < $5)
+		 RETURNING true`,
+		userID, key, fingerprint, expiredBefore, staleBefore).Scan(&reserved)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	r := &store.IdempotencyRecord{UserID: userID, Key: key}
+	var status *int32
+	err = s.pool.QueryRow(ctx,
+		`SELECT fingerprint, status_code, response, created_at FROM idempotency_keys
+		 WHERE user_id=$1 AND idempotency_key=$2`, userID, key).Scan(&r.Fingerprint, &status, &r.Response, &r.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// 两条语句之间记录被释放或清理，视为仍在处理中，由客户端稍后重试
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if status != nil {
+		r.Completed, r.StatusCode = true, int(*status)
+	}
+	return r, nil
+}
+
+func (s *idempotencyStore) CompleteIdempotencyKey(ctx context.Context, userID int, key string, statusCode int, response []byte) error {
+	tag, err := s.pool.Exec(ctx,
+		`UPDATE idempotency_keys SET status_code=$3, response=$4, completed_at=NOW()
+		 WHERE user_id=$1 AND idempotency_key=$2 AND status_code IS NULL`,
+		userID, key, statusCode, response)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *idempotencyStore) ReleaseIdempotencyKey(ctx context.Context, userID int, key string) error {
+	_, err := s.pool.Exec(ctx,
+		"DELETE FROM idempotency_keys WHERE user_id=$1 AND idempotency_key=$2 AND status_code IS NULL", userID, key)
+	return err
+}
+
+func (s *idempotencyStore) DeleteIdempotencyKeysBefore(ctx context.Context, before time.Time, limit int) (int, error) {
+	tag, err := s.pool.Exec(ctx,
+		`DELETE FROM idempotency_keys WHERE (user_id, idempotency_key) IN (
+			SELECT user_id, idempotency_key FROM idempotency_keys WHERE created_at < $1 LIMIT $2)`,
+		before, limit)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
@@ -0,0 +1,394 @@
+package postgres
+
+import (
+	"back/orderstate"
+	"back/store"
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type orderStore struct {
+	pool *pgxpool.Pool
+}
+
+func (s *orderStore) CountOrdersByStatus(ctx context.Context, userID int) (map[orderstate.Status]int, error) {
+	rows, err := s.pool.Query(ctx, "SELECT status, COUNT(*) FROM orders WHERE user_id=$1 GROUP BY status", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := map[orderstate.Status]int{}
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		counts[orderstate.Status(status)] = count
+	}
+	return counts, rows.Err()
+}
+
+func (s *orderStore) ListOrders(ctx context.Context, userID int, status orderstate.Status) ([]store.Order, error) {
+	var rows pgx.Rows
+	var err error
+	if status != "" {
+		rows, err = s.pool.Query(ctx,
+			`SELECT o.id, o.status, o.total_price, COALESCE(o.address,''), o.created_at, o.updated_at, COUNT(oi.id) as item_count
+			 FROM orders o LEFT JOIN order_items oi ON o.id = oi.order_id
+			 WHERE o.user_id=$1 AND o.status=$2 GROUP BY o.id ORDER BY o.created_at DESC`,
+			userID, string(status))
+	} else {
+		rows, err = s.pool.Query(ctx,
+			`SELECT o.id, o.status, o.total_price, COALESCE(o.address,''), o.created_at, o.updated_at, COUNT(oi.id) as item_count
+			 FROM orders o LEFT JOIN order_items oi ON o.id = oi.order_id
+			 WHERE o.user_id=$1 GROUP BY o.id ORDER BY o.created_at DESC`,
+			userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	orders := []store.Order{}
+	for rows.Next() {
+		o := store.Order{UserID: userID}
+		var st string
+		if err := rows.Scan(&o.ID, &st, &o.TotalPrice, &o.Address, &o.CreatedAt, &o.UpdatedAt, &o.ItemCount); err != nil {
+			return nil, err
+		}
+		o.Status = orderstate.Status(st)
+		orders = append(orders, o)
+	}
+	return orders, rows.Err()
+}
+
+func (s *orderStore) GetOrder(ctx context.Context, userID, orderID int) (*store.Order, error) {
+	o := &store.Order{UserID: userID}
+	var st string
+	err := s.pool.QueryRow(ctx,
+		`SELECT id, status, total_price, COALESCE(address,''), created_at, updated_at, COALESCE(cancel_reason,''),
+			COALESCE(ship_recipient,''), COALESCE(ship_phone,''), COALESCE(ship_province,''), COALESCE(ship_city,''),
+			COALESCE(ship_district,''), COALESCE(ship_detail,''), COALESCE(ship_postal_code,'')
+		 FROM orders WHERE id=$1 AND user_id=$2`,
+		orderID, userID).Scan(&o.ID, &st, &o.TotalPrice, &o.Address, &o.CreatedAt, &o.UpdatedAt, &o.CancelReason,
+		&o.Shipping.Recipient, &o.Shipping.Phone, &o.Shipping.Province, &o.Shipping.City,
+		&o.Shipping.District, &o.Shipping.Detail, &o.Shipping.PostalCode)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	o.Status = orderstate.Status(st)
+	rows, err := s.pool.Query(ctx,
+		`SELECT oi.id, COALESCE(oi.product_id, 0), COALESCE(oi.model_id, 0), oi.quantity, oi.price,
+			oi.title, oi.model_name, COALESCE(oi.image, ''),
+			EXISTS(SELECT 1 FROM product_reviews r WHERE r.order_item_id = oi.id),
+			COALESCE((SELECT rf.status FROM refunds rf WHERE rf.order_item_id = oi.id ORDER BY rf.id DESC LIMIT 1), '')
+		 FROM order_items oi WHERE oi.order_id=$1 ORDER BY oi.id`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	o.Items = []store.OrderItem{}
+	for rows.Next() {
+		var it store.OrderItem
+		var refund string
+		if err := rows.Scan(&it.ID, &it.ProductID, &it.ModelID, &it.Quantity, &it.Price,
+			&it.Title, &it.ModelName, &it.Image, &it.Reviewed, &refund); err != nil {
+			return nil, err
+		}
+		it.RefundStatus = store.RefundStatus(refund)
+		o.Items = append(o.Items, it)
+	}
+	o.ItemCount = len(o.Items)
+	return o, rows.Err()
+}
+
+func (s *orderStore) OrderHistory(ctx context.Context, orderID int) ([]orderstate.HistoryEntry, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT COALESCE(from_status,''), to_status, actor, COALESCE(note,''), created_at
+		 FROM order_status_history WHERE order_id=$1 ORDER BY id`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries := []orderstate.HistoryEntry{}
+	for rows.Next() {
+		var e orderstate.HistoryEntry
+		if err := rows.Scan(&e.From, &e.To, &e.Actor, &e.Note, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (s *orderStore) CreateOrder(ctx context.Context, userID int, ship store.ShippingAddress, actor string, modelIDs []int, build store.OrderBuilder) (int, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+	// 锁定涉及的型号行，直到事务结束前其他下单请求无法读写这些库存
+	models, err := lockModels(ctx, tx, modelIDs)
+	if err != nil {
+		return 0, err
+	}
+	order, err := build(models)
+	if err != nil {
+		return 0, err
+	}
+	// 扣减库存，同一型号合并数量（build 已校验每个型号的合计不超过库存，不会溢出）；
+	// stock 上的 CHECK (stock >= 0) 兜底防止超卖
+	need := map[int]int{}
+	for _, it := range order.Items {
+		need[it.ModelID] += it.Quantity
+	}
+	for _, id := range sortedKeys(need) {
+		if _, err := tx.Exec(ctx, "UPDATE product_models SET stock = stock - $1 WHERE id=$2", need[id], id); err != nil {
+			return 0, err
+		}
+	}
+	var orderID int
+	err = tx.QueryRow(ctx,
+		`INSERT INTO orders (user_id, status, total_price, address, created_at, updated_at,
+			ship_recipient, ship_phone, ship_province, ship_city, ship_district, ship_detail, ship_postal_code)
+		 VALUES ($1, $2, $3, $4, NOW(), NOW(), $5, $6, $7, $8, $9, $10, $11) RETURNING id`,
+		userID, string(orderstate.Pending), order.Total, ship.String(),
+		ship.Recipient, ship.Phone, ship.Province, ship.City, ship.District, ship.Detail, ship.PostalCode).Scan(&orderID)
+	if err != nil {
+		return 0, err
+	}
+	if err := recordHistory(ctx, tx, orderID, "", orderstate.Pending, actor, ""); err != nil {
+		return 0, err
+	}
+	// 商品标题、型号名称和主图在同一事务内复制到明细中，型号已加锁，读到的即下单时的内容
+	for _, it := range order.Items {
+		tag, err := tx.Exec(ctx,
+			`INSERT INTO order_items (order_id, product_id, model_id, quantity, price, title, model_name, image)
+			 SELECT $1, p.id, m.id, $4, $5, p.title, m.model_name,
+				(SELECT url FROM product_images WHERE product_id = p.id ORDER BY id LIMIT 1)
+			 FROM product_models m JOIN products p ON p.id = m.product_id
+			 WHERE p.id=$2 AND m.id=$3`,
+			orderID, it.ProductID, it.ModelID, it.Quantity, it.Price)
+		if err != nil {
+			return 0, err
+		}
+		if tag.RowsAffected() != 1 {
+			return 0, store.ErrNotFound
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return orderID, nil
+}
+
+func (s *orderStore) TransitionOrder(ctx context.Context, orderID int, to orderstate.Status, actor, note string) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if err := transition(ctx, tx, orderID, to, actor, note); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *orderStore) CancelOrder(ctx context.Context, orderID int, actor, reason string) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	// 先变更状态（内部锁定订单并校验），保证库存只会归还一次
+	if err := transition(ctx, tx, orderID, orderstate.Cancelled, actor, reason); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx,
+		`UPDATE product_models m SET stock = m.stock + s.qty
+		 FROM (SELECT model_id, SUM(quantity) AS qty FROM order_items WHERE order_id=$1 GROUP BY model_id) s
+		 WHERE m.id = s.model_id`, orderID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, "UPDATE orders SET cancel_reason=$1 WHERE id=$2", reason, orderID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *orderStore) ListPendingOrdersBefore(ctx context.Context, before time.Time, limit int) ([]int, error) {
+	rows, err := s.pool.Query(ctx,
+		"SELECT id FROM orders WHERE status=$1 AND created_at < $2 ORDER BY id LIMIT $3",
+		string(orderstate.Pending), before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (s *orderStore) ReviewOrderItem(ctx context.Context, orderID int, r store.NewReview, actor string) (bool, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+	// 锁定订单，与同一订单的其他评价串行，保证只有最后一条评价推进订单状态
+	var status string
+	err = tx.QueryRow(ctx, "SELECT status FROM orders WHERE id=$1 AND user_id=$2 FOR UPDATE", orderID, r.UserID).Scan(&status)
+	if err != nil {
+		return false, notFound(err)
+	}
+	// 商品或型号已删除、或已退款的明细无法评价，也不计入待评价数量
+	var productID int
+	err = tx.QueryRow(ctx,
+		`SELECT product_id FROM order_items oi
+		 WHERE oi.id=$1 AND oi.order_id=$2 AND oi.product_id IS NOT NULL AND oi.model_id IS NOT NULL
+			AND NOT EXISTS (SELECT 1 FROM refunds rf WHERE rf.order_item_id = oi.id AND rf.status=$3)`,
+		r.OrderItemID, orderID, string(store.RefundCompleted)).Scan(&productID)
+	if err != nil {
+		return false, notFound(err)
+	}
+	if orderstate.Status(status) != orderstate.ToReview {
+		return false, store.ErrInvalidState
+	}
+	var reviewID int
+	err = tx.QueryRow(ctx,
+		`INSERT INTO product_reviews (product_id, model_id, username, user_id, rating, content, order_item_id, status, created_at)
+		 SELECT product_id, model_id, $1, $2, $3, NULLIF($4, ''), id, $5, NOW() FROM order_items WHERE id=$6
+		 ON CONFLICT (order_item_id) DO NOTHING
+		 RETURNING id`,
+		r.Username, r.UserID, r.Rating, r.Content, string(r.Status), r.OrderItemID).Scan(&reviewID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, store.ErrConflict
+	}
+	if err != nil {
+		return false, err
+	}
+	for i, url := range r.Images {
+		_, err := tx.Exec(ctx, "INSERT INTO review_images (review_id, url, sort_order) VALUES ($1, $2, $3)", reviewID, url, i)
+		if err != nil {
+			return false, err
+		}
+	}
+	if r.Status == store.ReviewApproved {
+		if err := addReviewStats(ctx, tx, productID, r.Rating, 1); err != nil {
+			return false, err
+		}
+	}
+	var remaining int
+	err = tx.QueryRow(ctx,
+		`SELECT COUNT(*) FROM order_items oi
+		 WHERE oi.order_id=$1 AND oi.product_id IS NOT NULL AND oi.model_id IS NOT NULL
+			AND NOT EXISTS (SELECT 1 FROM product_reviews r WHERE r.order_item_id = oi.id)
+			AND NOT EXISTS (SELECT 1 FROM refunds rf WHERE rf.order_item_id = oi.id AND rf.status=$2)`,
+		orderID, string(store.RefundCompleted)).Scan(&remaining)
+	if err != nil {
+		return false, err
+	}
+	completed := remaining == 0
+	if completed {
+		if err := transition(ctx, tx, orderID, orderstate.Completed, actor, "全部商品已评价"); err != nil {
+			return false, err
+		}
+	}
+	return completed, tx.Commit(ctx)
+}
+
+// lockModels 按 id 顺序对型号行加 FOR UPDATE 锁，固定加锁顺序可以避免并发下单互相死锁
+func lockModels(ctx context.Context, tx pgx.Tx, modelIDs []int) (map[int]store.Model, error) {
+	ids := make([]int32, 0, len(modelIDs))
+	for _, id := range modelIDs {
+		ids = append(ids, int32(id))
+	}
+	rows, err := tx.Query(ctx,
+		"SELECT id, product_id, model_name, price, stock FROM product_models WHERE id = ANY($1) ORDER BY id FOR UPDATE", ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	models := map[int]store.Model{}
+	for rows.Next() {
+		var m store.Model
+		if err := rows.Scan(&m.ID, &m.ProductID, &m.Name, &m.Price, &m.Stock); err != nil {
+			return nil, err
+		}
+		models[m.ID] = m
+	}
+	return models, rows.Err()
+}
+
+// transition 锁定订单，按状态机校验后更新状态并写入状态记录
+func transition(ctx context.Context, tx pgx.Tx, orderID int, to orderstate.Status, actor, note string) error {
+	var from string
+	err := tx.QueryRow(ctx, "SELECT status FROM orders WHERE id=$1 FOR UPDATE", orderID).Scan(&from)
+	if err != nil {
+		return notFound(err)
+	}
+	if err := orderstate.Orders.Check(orderstate.Status(from), to); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, "UPDATE orders SET status=$1, updated_at=NOW() WHERE id=$2", string(to), orderID); err != nil {
+		return err
+	}
+	// 进入计入销量的状态（支付）时累加销量，离开（退款）时扣回
+	wasSale, isSale := orderstate.CountsAsSale(orderstate.Status(from)), orderstate.CountsAsSale(to)
+	if wasSale != isSale {
+		if err := updateSales(ctx, tx, orderID, isSale); err != nil {
+			return err
+		}
+	}
+	return recordHistory(ctx, tx, orderID, from, to, actor, note)
+}
+
+// updateSales 把订单中各商品的数量累加到（add 为 false 时从）product_stats.sales_count，
+// 扣回时跳过已单独退款的明细，它们的销量在退款完成时已经扣除
+func updateSales(ctx context.Context, tx pgx.Tx, orderID int, add bool) error {
+	if add {
+		_, err := tx.Exec(ctx,
+			`INSERT INTO product_stats (product_id, sales_count)
+			 SELECT product_id, SUM(quantity) FROM order_items
+			 WHERE order_id=$1 AND product_id IS NOT NULL GROUP BY product_id
+			 ON CONFLICT (product_id) DO UPDATE
+			 SET sales_count = product_stats.sales_count + EXCLUDED.sales_count, updated_at = NOW()`, orderID)
+		return err
+	}
+	_, err := tx.Exec(ctx,
+		`UPDATE product_stats st SET sales_count = GREATEST(st.sales_count - s.qty, 0), updated_at = NOW()
+		 FROM (SELECT product_id, SUM(quantity) AS qty FROM order_items oi
+			WHERE order_id=$1 AND NOT EXISTS (SELECT 1 FROM refunds rf WHERE rf.order_item_id = oi.id AND rf.status=$2)
+			GROUP BY product_id) s
+		 WHERE st.product_id = s.product_id`, orderID, string(store.RefundCompleted))
+	return err
+}
+
+func recordHistory(ctx context.Context, tx pgx.Tx, orderID int, from string, to orderstate.Status, actor, note string) error {
+	_, err := tx.Exec(ctx,
+		`INSERT INTO order_status_history (order_id, from_status, to_status, actor, note, created_at)
+		 VALUES ($1, NULLIF($2, ''), $3, $4, NULLIF($5, ''), NOW())`,
+		orderID, from, string(to), actor, note)
+	return err
+}
+
+func sortedKeys(m map[int]int) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
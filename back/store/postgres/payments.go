@@ -0,0 +1,193 @@
+package postgres
+
+import (
+	"back/orderstate"
+	"back/payment"
+	"back/store"
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type paymentStore struct {
+	pool *pgxpool.Pool
+}
+
+const paymentColumns = "id, order_id, provider, transaction_id, amount, status, COALESCE(pay_url,''), needs_refund, refunded_at IS NOT NULL, created_at, updated_at"
+
+func scanPayment(row pgx.Row) (*store.Payment, error) {
+	p := &store.Payment{}
+	var status string
+	err := row.Scan(&p.ID, &p.OrderID, &p.Provider, &p.TransactionID, &p.Amount, &status, &p.PayURL,
+		&p.NeedsRefund, &p.Refunded, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	p.Status = payment.Status(status)
+	return p, nil
+}
+
+func (s *paymentStore) CreatePayment(ctx context.Context, p *store.Payment) error {
+	err := s.pool.QueryRow(ctx,
+		`INSERT INTO payments (order_id, provider, transaction_id, amount, status, pay_url, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NOW(), NOW()) RETURNING id, created_at, updated_at`,
+		p.OrderID, p.Provider, p.TransactionID, p.Amount, string(payment.StatusPending), p.PayURL).Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+	if isUniqueViolation(err) {
+		return store.ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+	p.Status = payment.StatusPending
+	return nil
+}
+
+func (s *paymentStore) GetPayment(ctx context.Context, id int) (*store.Payment, error) {
+	p, err := scanPayment(s.pool.QueryRow(ctx, "SELECT "+paymentColumns+" FROM payments WHERE id=$1", id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return p, nil
+}
+
+func (s *paymentStore) LatestPayment(ctx context.Context, orderID int) (*store.Payment, error) {
+	p, err := scanPayment(s.pool.QueryRow(ctx,
+		"SELECT "+paymentColumns+" FROM payments WHERE order_id=$1 ORDER BY id DESC LIMIT 1", orderID))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return p, nil
+}
+
+func (s *paymentStore) OrderPayment(ctx context.Context, orderID int) (*store.Payment, error) {
+	p, err := scanPayment(s.pool.QueryRow(ctx,
+		"SELECT "+paymentColumns+" FROM payments WHERE order_id=$1 AND status=$2 AND NOT needs_refund ORDER BY id LIMIT 1",
+		orderID, string(payment.StatusSucceeded)))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return p, nil
+}
+
+func (s *paymentStore) SettlePayment(ctx context.Context, id int, status payment.Status, actor string) (bool, error) {
+	if !status.Final() {
+		return false, store.ErrInvalidState
+	}
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+	// 先锁支付记录再锁订单（transition 内），并发的查询、回调对同一笔支付串行执行，只有第一个生效
+	var orderID int
+	var current string
+	err = tx.QueryRow(ctx, "SELECT order_id, status FROM payments WHERE id=$1 FOR UPDATE", id).Scan(&orderID, &current)
+	if err != nil {
+		return false, notFound(err)
+	}
+	if payment.Status(current) != payment.StatusPending {
+		return false, store.ErrInvalidState
+	}
+	paid, err := settle(ctx, tx, id, orderID, status, actor)
+	if err != nil {
+		return false, err
+	}
+	return paid, tx.Commit(ctx)
+}
+
+func (s *paymentStore) ApplyPaymentEvent(ctx context.Context, e store.PaymentEvent, actor string) (bool, error) {
+	if !e.Status.Final() {
+		return false, store.ErrInvalidState
+	}
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+	// 锁定支付记录后再写通知，同一笔交易的通知（包括并发重试的同一通知）串行处理
+	var id, orderID int
+	var current string
+	err = tx.QueryRow(ctx,
+		"SELECT id, order_id, status FROM payments WHERE provider=$1 AND transaction_id=$2 FOR UPDATE",
+		e.Provider, e.TransactionID).Scan(&id, &orderID, &current)
+	if err != nil {
+		return false, notFound(err)
+	}
+	tag, err := tx.Exec(ctx,
+		`INSERT INTO payment_events (provider, event_id, payment_id, status, payload, received_at)
+		 VALUES ($1, $2, $3, $4, $5, NOW()) ON CONFLICT (provider, event_id) DO NOTHING`,
+		e.Provider, e.EventID, id, string(e.Status), string(e.Payload))
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, store.ErrConflict
+	}
+	paid := false
+	if lateSuccess(payment.Status(current), e.Status) || payment.Status(current) == payment.StatusPending {
+		if paid, err = settle(ctx, tx, id, orderID, e.Status, actor); err != nil {
+			return false, err
+		}
+	}
+	return paid, tx.Commit(ctx)
+}
+
+// lateSuccess 判断是否为已记为失败的支付收到成功通知：查询时渠道已没有该交易会被记为失败，
+// 但钱可能已经扣了，这时以渠道的成功通知为准重新结算
+func lateSuccess(current, status payment.Status) bool {
+	return current == payment.StatusFailed && status == payment.StatusSucceeded
+}
+
+// settle 把已锁定的待支付（或按 lateSuccess 重新结算的失败）记录更新为最终状态，支付成功且订单仍待付款时流转为待发货并返回 true
+func settle(ctx context.Context, tx pgx.Tx, id, orderID int, status payment.Status, actor string) (bool, error) {
+	if _, err := tx.Exec(ctx, "UPDATE payments SET status=$1, updated_at=NOW() WHERE id=$2", string(status), id); err != nil {
+		return false, err
+	}
+	if status != payment.StatusSucceeded {
+		return false, nil
+	}
+	err := transition(ctx, tx, orderID, orderstate.ToShip, actor, "支付成功")
+	var transErr *orderstate.TransitionError
+	if errors.As(err, &transErr) {
+		// 订单已取消或已由其他支付付款，支付仍记为成功，标记后由后台任务原路退回
+		_, err = tx.Exec(ctx, "UPDATE payments SET needs_refund=true WHERE id=$1", id)
+		return false, err
+	}
+	return err == nil, err
+}
+
+func (s *paymentStore) ListPaymentsToRefund(ctx context.Context, limit int) ([]store.Payment, error) {
+	rows, err := s.pool.Query(ctx,
+		"SELECT "+paymentColumns+" FROM payments WHERE needs_refund AND refunded_at IS NULL ORDER BY id LIMIT $1", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []store.Payment{}
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *p)
+	}
+	return list, rows.Err()
+}
+
+func (s *paymentStore) MarkPaymentRefunded(ctx context.Context, id int, providerRefundID string) error {
+	tag, err := s.pool.Exec(ctx,
+		`UPDATE payments SET refunded_at=NOW(), provider_refund_id=NULLIF($1, ''), updated_at=NOW()
+		 WHERE id=$2 AND needs_refund AND refunded_at IS NULL`, providerRefundID, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		if _, err := s.GetPayment(ctx, id); err != nil {
+			return err
+		}
+		return store.ErrInvalidState
+	}
+	return nil
+}
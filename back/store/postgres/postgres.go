@@ -0,0 +1,40 @@
+package postgres
+
+import (
+	"back/store"
+	"errors"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// New 返回基于 PostgreSQL 的全部存储实现
+func New(pool *pgxpool.Pool) store.Stores {
+	return store.Stores{
+		Users:       &userStore{pool: pool},
+		Products:    &productStore{pool: pool},
+		Categories:  &categoryStore{pool: pool},
+		Reviews:     &reviewStore{pool: pool},
+		Addresses:   &addressStore{pool: pool},
+		Carts:       &cartStore{pool: pool},
+		Orders:      &orderStore{pool: pool},
+		Payments:    &paymentStore{pool: pool},
+		Refunds:     &refundStore{pool: pool},
+		Idempotency: &idempotencyStore{pool: pool},
+	}
+}
+
+// notFound 把 pgx.ErrNoRows 转成 store.ErrNotFound，其他错误原样返回
+func notFound(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return store.ErrNotFound
+	}
+	return err
+}
+
+// isUniqueViolation 判断是否违反唯一约束
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
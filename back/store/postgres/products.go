@@ -0,0 +1,213 @@
+package postgres
+
+import (
+	"back/store"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type productStore struct {
+	pool *pgxpool.Pool
+}
+
+// 各排序方式对应的排序键（列名来自 listProducts 中的 filtered）
+var productSortColumns = map[store.ProductSort]string{
+	store.SortNewest:    "id::numeric",
+	store.SortPriceAsc:  "min_price",
+	store.SortPriceDesc: "min_price",
+	store.SortSales:     "sales",
+	store.SortRating:    "rating",
+}
+
+// 平均评分，保留两位小数，与 store.ProductStats.Rating 一致
+const ratingExpr = "COALESCE(ROUND(st.rating_sum::numeric / NULLIF(st.review_count,0), 2),0)"
+
+// 商品列表：主信息+首图+最低价+库存合计+销量/评分（product_stats），按条件筛选后游标分页
+func (s *productStore) ListProducts(ctx context.Context, q store.ProductQuery) (*store.ProductPage, error) {
+	args := []interface{}{}
+	where := []string{"TRUE"}
+	if q.CategoryIDs != nil {
+		args = append(args, q.CategoryIDs)
+		where = append(where, fmt.Sprintf("p.category_id = ANY($%d)", len(args)))
+	}
+	// 型号条件：价格区间、有库存
+	modelCond := []string{}
+	if q.MinPrice != nil {
+		args = append(args, *q.MinPrice)
+		modelCond = append(modelCond, fmt.Sprintf("m.price >= $%d", len(args)))
+	}
+	if q.MaxPrice != nil {
+		args = append(args, *q.MaxPrice)
+		modelCond = append(modelCond, fmt.Sprintf("m.price <= $%d", len(args)))
+	}
+	if q.InStock {
+		modelCond = append(modelCond, "m.stock > 0")
+	}
+	priceExpr, having := "MIN(m.price)", ""
+	if len(modelCond) > 0 {
+		cond := strings.Join(modelCond, " AND ")
+		priceExpr = "MIN(m.price) FILTER (WHERE " + cond + ")"
+		having = "HAVING COUNT(m.id) FILTER (WHERE " + cond + ") > 0"
+	}
+	filtered := `WITH filtered AS (
+		SELECT p.id, p.title, COALESCE(p.category_id,0) AS category_id, COALESCE(c.name,'') AS category, COALESCE(` + priceExpr + `,0) AS min_price, COALESCE(img.url,'') AS img,
+			COALESCE(SUM(m.stock),0) AS stock, COALESCE(st.sales_count,0) AS sales, ` + ratingExpr + ` AS rating,
+			COALESCE(st.review_count,0) AS review_count
+		FROM products p
+		LEFT JOIN categories c ON c.id = p.category_id
+		LEFT JOIN product_models m ON p.id = m.product_id
+		LEFT JOIN product_images img ON p.id = img.product_id AND img.id = (
+			SELECT id FROM product_images WHERE product_id = p.id LIMIT 1)
+		LEFT JOIN product_stats st ON st.product_id = p.id
+		WHERE ` + strings.Join(where, " AND ") + `
+		GROUP BY p.id, c.name, img.url, st.product_id
+		` + having + `
+	)`
+
+	page := &store.ProductPage{Items: []store.ProductSummary{}}
+	if err := s.pool.QueryRow(ctx, filtered+" SELECT COUNT(*) FROM filtered", args...).Scan(&page.Total); err != nil {
+		return nil, err
+	}
+
+	key, dir, cmp := productSortColumns[q.Sort], "DESC", "<"
+	if q.Sort.Ascending() {
+		dir, cmp = "ASC", ">"
+	}
+	cursorCond := "TRUE"
+	if q.After != nil {
+		args = append(args, strconv.FormatFloat(q.After.Key, 'f', -1, 64), q.After.ID)
+		cursorCond = fmt.Sprintf("(%s, id) %s ($%d::numeric, $%d)", key, cmp, len(args)-1, len(args))
+	}
+	// 多取一条判断是否还有下一页
+	args = append(args, q.Limit+1)
+	rows, err := s.pool.Query(ctx, filtered+`
+		SELECT id, title, category_id, category, min_price, img, stock, sales, rating, review_count FROM filtered
+		WHERE `+cursorCond+`
+		ORDER BY `+key+` `+dir+`, id `+dir+`
+		LIMIT $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p store.ProductSummary
+		if err := rows.Scan(&p.ID, &p.Title, &p.CategoryID, &p.Category, &p.MinPrice, &p.Img, &p.Stock, &p.Sales, &p.Rating, &p.ReviewCount); err != nil {
+			return nil, err
+		}
+		page.Items = append(page.Items, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(page.Items) > q.Limit {
+		page.Items = page.Items[:q.Limit]
+		last := page.Items[q.Limit-1]
+		page.Next = &store.ProductCursor{Key: q.Sort.Key(last), ID: last.ID}
+	}
+	return page, nil
+}
+
+// 转义 LIKE 通配符
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// 商品搜索：每个关键词须出现在标题或描述中（ILIKE 子串匹配，适用于中文），
+// 或与标题中的某个词足够相似（pg_trgm 的 <% 运算符，容忍英文拼写错误）。
+// 相关度：标题命中 3 分、描述命中 1 分，再加上与标题的相似度 ×2，多个关键词累加
+func (s *productStore) SearchProducts(ctx context.Context, q store.ProductSearch) ([]store.ProductSearchResult, error) {
+	terms := make([]string, len(q.Terms))
+	patterns := make([]string, len(q.Terms))
+	for i, t := range q.Terms {
+		terms[i] = t
+		patterns[i] = "%" + likeEscaper.Replace(t) + "%"
+	}
+	args := []interface{}{terms, patterns}
+	where := []string{}
+	for i := range terms {
+		where = append(where, fmt.Sprintf("(p.title ILIKE $%d OR p.description ILIKE $%d OR $%d <%% p.title)", len(args)+1, len(args)+1, len(args)+2))
+		args = append(args, patterns[i], terms[i])
+	}
+	if q.CategoryIDs != nil {
+		args = append(args, q.CategoryIDs)
+		where = append(where, fmt.Sprintf("p.category_id = ANY($%d)", len(args)))
+	}
+	args = append(args, q.Limit)
+	query := `SELECT p.id, p.title, COALESCE(p.category_id,0), COALESCE(c.name,''), COALESCE(p.description,''), COALESCE(MIN(m.price),0) as min_price, COALESCE(img.url,''), COALESCE(SUM(m.stock),0),
+			COALESCE(st.sales_count,0), ` + ratingExpr + `, COALESCE(st.review_count,0), r.rank
+		FROM products p
+		CROSS JOIN LATERAL (
+			SELECT SUM(
+				CASE WHEN p.title ILIKE t.pattern THEN 3 ELSE 0 END
+				+ CASE WHEN p.description ILIKE t.pattern THEN 1 ELSE 0 END
+				+ word_similarity(t.term, p.title) * 2) AS rank
+			FROM unnest($1::text[], $2::text[]) AS t(term, pattern)
+		) r
+		LEFT JOIN categories c ON c.id = p.category_id
+		LEFT JOIN product_stats st ON st.product_id = p.id
+		LEFT JOIN product_models m ON p.id = m.product_id
+		LEFT JOIN product_images img ON p.id = img.product_id AND img.id = (
+			SELECT id FROM product_images WHERE product_id = p.id LIMIT 1)
+		WHERE ` + strings.Join(where, " AND ") + `
+		GROUP BY p.id, c.name, img.url, st.product_id, r.rank
+		ORDER BY r.rank DESC, p.id DESC
+		LIMIT $` + fmt.Sprint(len(args))
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	results := []store.ProductSearchResult{}
+	for rows.Next() {
+		var r store.ProductSearchResult
+		if err := rows.Scan(&r.ID, &r.Title, &r.CategoryID, &r.Category, &r.Description, &r.MinPrice, &r.Img, &r.Stock, &r.Sales, &r.Rating, &r.ReviewCount, &r.Rank); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+func (s *productStore) GetProduct(ctx context.Context, id int) (*store.Product, error) {
+	p := &store.Product{ID: id, Images: []string{}, Models: []store.Model{}}
+	err := s.pool.QueryRow(ctx, `SELECT p.title, COALESCE(p.category_id,0), COALESCE(c.name,''), COALESCE(p.description,''),
+			COALESCE(st.sales_count,0), `+ratingExpr+`, COALESCE(st.review_count,0)
+		FROM products p
+		LEFT JOIN categories c ON c.id = p.category_id
+		LEFT JOIN product_stats st ON st.product_id = p.id
+		WHERE p.id=$1`, id).
+		Scan(&p.Title, &p.CategoryID, &p.Category, &p.Description, &p.Sales, &p.Rating, &p.ReviewCount)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	// 图片
+	imgRows, err := s.pool.Query(ctx, "SELECT url FROM product_images WHERE product_id=$1 ORDER BY id", id)
+	if err != nil {
+		return nil, err
+	}
+	for imgRows.Next() {
+		var url string
+		if err := imgRows.Scan(&url); err != nil {
+			imgRows.Close()
+			return nil, err
+		}
+		p.Images = append(p.Images, url)
+	}
+	imgRows.Close()
+	// 型号
+	modelRows, err := s.pool.Query(ctx, "SELECT id, product_id, model_name, price, stock FROM product_models WHERE product_id=$1 ORDER BY id", id)
+	if err != nil {
+		return nil, err
+	}
+	defer modelRows.Close()
+	for modelRows.Next() {
+		var m store.Model
+		if err := modelRows.Scan(&m.ID, &m.ProductID, &m.Name, &m.Price, &m.Stock); err != nil {
+			return nil, err
+		}
+		p.Models = append(p.Models, m)
+	}
+	return p, modelRows.Err()
+}
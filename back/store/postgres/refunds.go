@@ -0,0 +1,294 @@
+package postgres
+
+import (
+	"back/orderstate"
+	"back/store"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type refundStore struct {
+	pool *pgxpool.Pool
+}
+
+const refundColumns = `r.id, r.order_id, r.order_item_id, r.user_id, r.type, r.reason, r.amount, r.status,
+	COALESCE(r.reviewed_by,''), COALESCE(r.review_note,''), COALESCE(r.provider_refund_id,''), r.created_at, r.updated_at,
+	oi.title, oi.model_name, oi.quantity,
+	COALESCE((SELECT array_agg(i.object_key ORDER BY i.sort_order, i.id) FROM refund_images i WHERE i.refund_id = r.id), '{}')`
+
+const refundFrom = " FROM refunds r JOIN order_items oi ON oi.id = r.order_item_id"
+
+func scanRefund(row pgx.Row) (*store.Refund, error) {
+	r := &store.Refund{}
+	var typ, status string
+	err := row.Scan(&r.ID, &r.OrderID, &r.OrderItemID, &r.UserID, &typ, &r.Reason, &r.Amount, &status,
+		&r.ReviewedBy, &r.ReviewNote, &r.ProviderRefundID, &r.CreatedAt, &r.UpdatedAt,
+		&r.Title, &r.ModelName, &r.Quantity, &r.ImageKeys)
+	if err != nil {
+		return nil, err
+	}
+	r.Type, r.Status = store.RefundType(typ), store.RefundStatus(status)
+	return r, nil
+}
+
+func (s *refundStore) CreateRefund(ctx context.Context, r *store.Refund, allowed []orderstate.Status) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	// 锁定订单，与状态流转和同一订单的其他申请串行
+	var status string
+	err = tx.QueryRow(ctx, "SELECT status FROM orders WHERE id=$1 AND user_id=$2 FOR UPDATE", r.OrderID, r.UserID).Scan(&status)
+	if err != nil {
+		return notFound(err)
+	}
+	var amount float64
+	err = tx.QueryRow(ctx,
+		"SELECT price * quantity FROM order_items WHERE id=$1 AND order_id=$2 AND product_id IS NOT NULL AND model_id IS NOT NULL",
+		r.OrderItemID, r.OrderID).Scan(&amount)
+	if err != nil {
+		return notFound(err)
+	}
+	if !containsStatus(allowed, orderstate.Status(status)) {
+		return store.ErrInvalidState
+	}
+	err = tx.QueryRow(ctx,
+		`INSERT INTO refunds (order_id, order_item_id, user_id, type, reason, amount, status, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW()) RETURNING id`,
+		r.OrderID, r.OrderItemID, r.UserID, string(r.Type), r.Reason, amount, string(store.RefundRequested)).Scan(&r.ID)
+	if isUniqueViolation(err) {
+		return store.ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+	for i, key := range r.ImageKeys {
+		_, err := tx.Exec(ctx, "INSERT INTO refund_images (refund_id, object_key, sort_order) VALUES ($1, $2, $3)", r.ID, key, i)
+		if err != nil {
+			return err
+		}
+	}
+	created, err := scanRefund(tx.QueryRow(ctx, "SELECT "+refundColumns+refundFrom+" WHERE r.id=$1", r.ID))
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	*r = *created
+	return nil
+}
+
+func (s *refundStore) GetRefund(ctx context.Context, id int) (*store.Refund, error) {
+	r, err := scanRefund(s.pool.QueryRow(ctx, "SELECT "+refundColumns+refundFrom+" WHERE r.id=$1", id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return r, nil
+}
+
+func (s *refundStore) ListRefunds(ctx context.Context, q store.RefundQuery) (*store.RefundPage, error) {
+	args := []interface{}{}
+	where := []string{"TRUE"}
+	if q.UserID > 0 {
+		args = append(args, q.UserID)
+		where = append(where, fmt.Sprintf("r.user_id=$%d", len(args)))
+	}
+	if q.OrderID > 0 {
+		args = append(args, q.OrderID)
+		where = append(where, fmt.Sprintf("r.order_id=$%d", len(args)))
+	}
+	if q.Status != "" {
+		args = append(args, string(q.Status))
+		where = append(where, fmt.Sprintf("r.status=$%d", len(args)))
+	}
+	cond := strings.Join(where, " AND ")
+	page := &store.RefundPage{Items: []store.Refund{}}
+	if err := s.pool.QueryRow(ctx, "SELECT COUNT(*) FROM refunds r WHERE "+cond, args...).Scan(&page.Total); err != nil {
+		return nil, err
+	}
+	orderBy := "r.created_at DESC, r.id DESC"
+	if q.Oldest {
+		orderBy = "r.created_at, r.id"
+	}
+	query := "SELECT " + refundColumns + refundFrom + " WHERE " + cond + " ORDER BY " + orderBy
+	if q.Limit > 0 {
+		args = append(args, q.Limit, q.Offset)
+		query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	}
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		r, err := scanRefund(rows)
+		if err != nil {
+			return nil, err
+		}
+		page.Items = append(page.Items, *r)
+	}
+	return page, rows.Err()
+}
+
+func (s *refundStore) RejectRefund(ctx context.Context, id int, actor, note string) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	status, err := lockRefund(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if status != store.RefundRequested {
+		return store.ErrInvalidState
+	}
+	_, err = tx.Exec(ctx,
+		`UPDATE refunds SET status=$1, reviewed_by=$2, review_note=NULLIF($3, ''), reviewed_at=NOW(), updated_at=NOW()
+		 WHERE id=$4`, string(store.RefundRejected), actor, note, id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *refundStore) ApproveRefund(ctx context.Context, id int, actor, note string) (*store.Refund, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	var orderID int
+	var typ string
+	if err := tx.QueryRow(ctx, "SELECT order_id, type FROM refunds WHERE id=$1", id).Scan(&orderID, &typ); err != nil {
+		return nil, notFound(err)
+	}
+	// 先锁订单再锁申请，与 CompleteRefund 的加锁顺序一致，并保证归还库存时订单状态不变
+	var orderStatus string
+	if err := tx.QueryRow(ctx, "SELECT status FROM orders WHERE id=$1 FOR UPDATE", orderID).Scan(&orderStatus); err != nil {
+		return nil, notFound(err)
+	}
+	status, err := lockRefund(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	switch status {
+	case store.RefundApproved:
+		// 上次调用渠道退款失败后重试，库存已处理
+	case store.RefundRequested:
+		_, err = tx.Exec(ctx,
+			`UPDATE refunds SET status=$1, reviewed_by=$2, review_note=NULLIF($3, ''), reviewed_at=NOW(), updated_at=NOW()
+			 WHERE id=$4`, string(store.RefundApproved), actor, note, id)
+		if err != nil {
+			return nil, err
+		}
+		if restocks(store.RefundType(typ), orderstate.Status(orderStatus)) {
+			// 型号已删除时 model_id 为空，不归还库存
+			_, err = tx.Exec(ctx,
+				`UPDATE product_models m SET stock = m.stock + oi.quantity
+				 FROM refunds r JOIN order_items oi ON oi.id = r.order_item_id
+				 WHERE r.id=$1 AND m.id = oi.model_id`, id)
+			if err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, store.ErrInvalidState
+	}
+	r, err := scanRefund(tx.QueryRow(ctx, "SELECT "+refundColumns+refundFrom+" WHERE r.id=$1", id))
+	if err != nil {
+		return nil, err
+	}
+	return r, tx.Commit(ctx)
+}
+
+func (s *refundStore) CompleteRefund(ctx context.Context, id int, providerRefundID, actor string) (bool, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+	var orderID, itemID int
+	err = tx.QueryRow(ctx, "SELECT order_id, order_item_id FROM refunds WHERE id=$1", id).Scan(&orderID, &itemID)
+	if err != nil {
+		return false, notFound(err)
+	}
+	// 先锁订单再锁申请，与 CreateRefund 和订单状态流转的加锁顺序一致
+	var orderStatus string
+	if err := tx.QueryRow(ctx, "SELECT status FROM orders WHERE id=$1 FOR UPDATE", orderID).Scan(&orderStatus); err != nil {
+		return false, notFound(err)
+	}
+	status, err := lockRefund(ctx, tx, id)
+	if err != nil {
+		return false, err
+	}
+	if status != store.RefundApproved {
+		return false, store.ErrInvalidState
+	}
+	_, err = tx.Exec(ctx,
+		`UPDATE refunds SET status=$1, provider_refund_id=NULLIF($2, ''), completed_at=NOW(), updated_at=NOW() WHERE id=$3`,
+		string(store.RefundCompleted), providerRefundID, id)
+	if err != nil {
+		return false, err
+	}
+	if orderstate.CountsAsSale(orderstate.Status(orderStatus)) {
+		_, err = tx.Exec(ctx,
+			`UPDATE product_stats st SET sales_count = GREATEST(st.sales_count - oi.quantity, 0), updated_at = NOW()
+			 FROM order_items oi WHERE oi.id=$1 AND st.product_id = oi.product_id`, itemID)
+		if err != nil {
+			return false, err
+		}
+	}
+	var remaining, unreviewed int
+	err = tx.QueryRow(ctx,
+		`SELECT COUNT(*), COUNT(*) FILTER (WHERE oi.product_id IS NOT NULL AND oi.model_id IS NOT NULL
+			AND NOT EXISTS (SELECT 1 FROM product_reviews pr WHERE pr.order_item_id = oi.id))
+		 FROM order_items oi
+		 WHERE oi.order_id=$1 AND NOT EXISTS (SELECT 1 FROM refunds r WHERE r.order_item_id = oi.id AND r.status=$2)`,
+		orderID, string(store.RefundCompleted)).Scan(&remaining, &unreviewed)
+	if err != nil {
+		return false, err
+	}
+	// 已完成的订单不再流转到退款状态，逐件扣回的销量已经足够
+	refunded := remaining == 0 && orderstate.Orders.Check(orderstate.Status(orderStatus), orderstate.Refund) == nil
+	switch {
+	case refunded:
+		err = transition(ctx, tx, orderID, orderstate.Refund, actor, "全部商品已退款")
+	case orderstate.Status(orderStatus) == orderstate.ToReview && unreviewed == 0:
+		err = transition(ctx, tx, orderID, orderstate.Completed, actor, "全部商品已评价")
+	}
+	if err != nil {
+		return false, err
+	}
+	return refunded, tx.Commit(ctx)
+}
+
+// lockRefund 锁定申请并返回当前状态
+func lockRefund(ctx context.Context, tx pgx.Tx, id int) (store.RefundStatus, error) {
+	var status string
+	if err := tx.QueryRow(ctx, "SELECT status FROM refunds WHERE id=$1 FOR UPDATE", id).Scan(&status); err != nil {
+		return "", notFound(err)
+	}
+	return store.RefundStatus(status), nil
+}
+
+// restocks 判断同意申请时是否归还库存：退货的商品会寄回，未发货的订单商品还在仓库；
+// 已发货后的仅退款商品留在用户手中，不归还
+func restocks(t store.RefundType, orderStatus orderstate.Status) bool {
+	return t == store.RefundReturn || orderStatus == orderstate.ToShip
+}
+
+func containsStatus(list []orderstate.Status, s orderstate.Status) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
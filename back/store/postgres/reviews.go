@@ -0,0 +1,154 @@
+package postgres
+
+import (
+	"back/store"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type reviewStore struct {
+	pool *pgxpool.Pool
+}
+
+var reviewOrderBy = map[store.ReviewSort]string{
+	store.ReviewSortNewest:  "r.created_at DESC, r.id DESC",
+	store.ReviewSortHelpful: "r.helpful_count DESC, r.created_at DESC, r.id DESC",
+	store.ReviewSortOldest:  "r.created_at, r.id",
+}
+
+func (s *reviewStore) ListReviews(ctx context.Context, q store.ReviewQuery) (*store.ReviewPage, error) {
+	args := []interface{}{}
+	where := []string{"TRUE"}
+	if q.ProductID > 0 {
+		args = append(args, q.ProductID)
+		where = append(where, fmt.Sprintf("r.product_id=$%d", len(args)))
+	}
+	if q.Status != "" {
+		args = append(args, string(q.Status))
+		where = append(where, fmt.Sprintf("r.status=$%d", len(args)))
+	}
+	if q.Rating > 0 {
+		args = append(args, q.Rating)
+		where = append(where, fmt.Sprintf("r.rating=$%d", len(args)))
+	}
+	cond := strings.Join(where, " AND ")
+	page := &store.ReviewPage{Items: []store.Review{}}
+	if err := s.pool.QueryRow(ctx, "SELECT COUNT(*) FROM product_reviews r WHERE "+cond, args...).Scan(&page.Total); err != nil {
+		return nil, err
+	}
+	orderBy, ok := reviewOrderBy[q.Sort]
+	if !ok {
+		orderBy = reviewOrderBy[store.ReviewSortNewest]
+	}
+	query := `SELECT r.id, r.product_id, r.username, r.rating, COALESCE(r.content,''), r.created_at, r.model_id,
+			COALESCE(r.order_item_id,0), r.status, r.helpful_count,
+			COALESCE((SELECT array_agg(i.url ORDER BY i.sort_order, i.id) FROM review_images i WHERE i.review_id = r.id), '{}')
+		FROM product_reviews r
+		WHERE ` + cond + `
+		ORDER BY ` + orderBy
+	if q.Limit > 0 {
+		args = append(args, q.Limit, q.Offset)
+		query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	}
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r store.Review
+		var status string
+		err := rows.Scan(&r.ID, &r.ProductID, &r.Username, &r.Rating, &r.Content, &r.CreatedAt, &r.ModelID,
+			&r.OrderItemID, &status, &r.HelpfulCount, &r.Images)
+		if err != nil {
+			return nil, err
+		}
+		r.Status = store.ReviewStatus(status)
+		page.Items = append(page.Items, r)
+	}
+	return page, rows.Err()
+}
+
+func (s *reviewStore) SetReviewStatus(ctx context.Context, reviewID int, status store.ReviewStatus, actor string) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	var productID, rating int
+	var from string
+	err = tx.QueryRow(ctx, "SELECT product_id, rating, status FROM product_reviews WHERE id=$1 FOR UPDATE", reviewID).
+		Scan(&productID, &rating, &from)
+	if err != nil {
+		return notFound(err)
+	}
+	_, err = tx.Exec(ctx, "UPDATE product_reviews SET status=$1, moderated_by=$2, moderated_at=NOW() WHERE id=$3",
+		string(status), actor, reviewID)
+	if err != nil {
+		return err
+	}
+	wasApproved, isApproved := store.ReviewStatus(from) == store.ReviewApproved, status == store.ReviewApproved
+	if wasApproved != isApproved {
+		delta := 1
+		if wasApproved {
+			delta = -1
+		}
+		if err := addReviewStats(ctx, tx, productID, rating, delta); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *reviewStore) VoteHelpful(ctx context.Context, reviewID, userID int, helpful bool) (int, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+	var authorID int
+	var status string
+	var count int
+	err = tx.QueryRow(ctx,
+		"SELECT COALESCE(user_id,0), status, helpful_count FROM product_reviews WHERE id=$1 FOR UPDATE", reviewID).
+		Scan(&authorID, &status, &count)
+	if err != nil {
+		return 0, notFound(err)
+	}
+	if store.ReviewStatus(status) != store.ReviewApproved {
+		return 0, store.ErrNotFound
+	}
+	if authorID == userID {
+		return 0, store.ErrInvalidState
+	}
+	query, delta := "INSERT INTO review_votes (review_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", 1
+	if !helpful {
+		query, delta = "DELETE FROM review_votes WHERE review_id=$1 AND user_id=$2", -1
+	}
+	tag, err := tx.Exec(ctx, query, reviewID, userID)
+	if err != nil {
+		return 0, err
+	}
+	if tag.RowsAffected() > 0 {
+		err = tx.QueryRow(ctx, "UPDATE product_reviews SET helpful_count = helpful_count + $1 WHERE id=$2 RETURNING helpful_count",
+			delta, reviewID).Scan(&count)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return count, tx.Commit(ctx)
+}
+
+// addReviewStats 把一条评价计入（delta=1）或移出（delta=-1）商品评分统计
+func addReviewStats(ctx context.Context, tx pgx.Tx, productID, rating, delta int) error {
+	_, err := tx.Exec(ctx,
+		`INSERT INTO product_stats (product_id, review_count, rating_sum) VALUES ($1, GREATEST($3, 0), GREATEST($2 * $3, 0))
+		 ON CONFLICT (product_id) DO UPDATE
+		 SET review_count = product_stats.review_count + $3, rating_sum = product_stats.rating_sum + $2 * $3, updated_at = NOW()`,
+		productID, rating, delta)
+	return err
+}
@@ -0,0 +1,75 @@
+package postgres
+
+import (
+	"back/store"
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type userStore struct {
+	pool *pgxpool.Pool
+}
+
+const userColumns = "id, username, password, COALESCE(nickname,''), COALESCE(address,''), COALESCE(avatar,''), role"
+
+func (s *userStore) GetUser(ctx context.Context, id int) (*store.User, error) {
+	u := &store.User{}
+	err := s.pool.QueryRow(ctx, "SELECT "+userColumns+" FROM users WHERE id=$1", id).
+		Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Nickname, &u.Address, &u.Avatar, &u.Role)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return u, nil
+}
+
+func (s *userStore) GetUserByUsername(ctx context.Context, username string) (*store.User, error) {
+	u := &store.User{}
+	err := s.pool.QueryRow(ctx, "SELECT "+userColumns+" FROM users WHERE username=$1", username).
+		Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Nickname, &u.Address, &u.Avatar, &u.Role)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return u, nil
+}
+
+func (s *userStore) CreateUser(ctx context.Context, u *store.User) error {
+	err := s.pool.QueryRow(ctx,
+		"INSERT INTO users (username, password, nickname, address) VALUES ($1, $2, $3, $4) RETURNING id, role",
+		u.Username, u.PasswordHash, u.Nickname, u.Address).Scan(&u.ID, &u.Role)
+	if isUniqueViolation(err) {
+		return store.ErrConflict
+	}
+	return err
+}
+
+func (s *userStore) UpdatePassword(ctx context.Context, id int, oldHash, newHash string) error {
+	_, err := s.pool.Exec(ctx, "UPDATE users SET password=$1 WHERE id=$2 AND password=$3", newHash, id, oldHash)
+	return err
+}
+
+func (s *userStore) UpdateAddress(ctx context.Context, id int, address string) error {
+	_, err := s.pool.Exec(ctx, "UPDATE users SET address=$1 WHERE id=$2", address, id)
+	return err
+}
+
+func (s *userStore) UpdateNickname(ctx context.Context, id int, nickname string) error {
+	_, err := s.pool.Exec(ctx, "UPDATE users SET nickname=$1 WHERE id=$2", nickname, id)
+	return err
+}
+
+func (s *userStore) UpdateAvatar(ctx context.Context, id int, avatar string) (string, error) {
+	// 子查询读到的是更新前的快照，FOR UPDATE 保证并发上传时每个旧头像只被返回一次
+	var old string
+	err := s.pool.QueryRow(ctx, `
+		UPDATE users u SET avatar=$1
+		FROM (SELECT id, COALESCE(avatar,'') AS avatar FROM users WHERE id=$2 FOR UPDATE) old
+		WHERE u.id=old.id
+		RETURNING old.avatar`, avatar, id).Scan(&old)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return old, err
+}
@@ -0,0 +1,563 @@
+package store
+
+import (
+	"back/orderstate"
+	"back/payment"
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrNotFound 记录不存在（或不属于当前用户）
+var ErrNotFound = errors.New("记录不存在")
+
+// ErrConflict 唯一约束冲突，如用户名已存在
+var ErrConflict = errors.New("记录已存在")
+
+// ErrInvalidState 记录当前状态不允许该操作，如订单已不在待评价状态
+var ErrInvalidState = errors.New("记录状态不允许该操作")
+
+// ErrLimitExceeded 记录数量已达上限，如用户的收货地址数
+var ErrLimitExceeded = errors.New("数量已达上限")
+
+// 用户角色，对应 users.role
+const (
+	RoleUser  = "user"
+	RoleStaff = "staff"
+	RoleAdmin = "admin"
+)
+
+// User 用户
+type User struct {
+	ID           int
+	Username     string
+	PasswordHash string
+	Nickname     string
+	Address      string
+	Avatar       string
+	Role         string
+}
+
+// ProductSummary 商品列表项
+type ProductSummary struct {
+	ID         int
+	Title      string
+	CategoryID int    // 0 表示未分类
+	Category   string // 分类名称
+	MinPrice   float64
+	Img        string
+	Stock      int
+	ProductStats
+}
+
+// ProductStats 商品统计，由 product_stats 表维护
+type ProductStats struct {
+	Sales       int     // 已付款且未退款订单中的销量
+	Rating      float64 // 平均评分，保留两位小数，无评价时为 0
+	ReviewCount int
+}
+
+// Category 商品分类，ParentID 为 0 表示一级分类
+type Category struct {
+	ID           int
+	ParentID     int
+	Name         string
+	Slug         string
+	SortOrder    int
+	ProductCount int // 直接挂在该分类下的商品数，不含子分类
+}
+
+// ProductSort 商品列表排序方式
+type ProductSort string
+
+const (
+	SortNewest    ProductSort = "newest" // 最新上架（id 倒序）
+	SortPriceAsc  ProductSort = "price_asc"
+	SortPriceDesc ProductSort = "price_desc"
+	SortSales     ProductSort = "sales"
+	SortRating    ProductSort = "rating"
+)
+
+// Valid 判断是否为支持的排序方式
+func (s ProductSort) Valid() bool {
+	switch s {
+	case SortNewest, SortPriceAsc, SortPriceDesc, SortSales, SortRating:
+		return true
+	}
+	return false
+}
+
+// Ascending 是否升序，id 作为次要排序键与主键同向
+func (s ProductSort) Ascending() bool {
+	return s == SortPriceAsc
+}
+
+// Key 返回商品在该排序方式下的排序键
+func (s ProductSort) Key(p ProductSummary) float64 {
+	switch s {
+	case SortPriceAsc, SortPriceDesc:
+		return p.MinPrice
+	case SortSales:
+		return float64(p.Sales)
+	case SortRating:
+		return p.Rating
+	}
+	return float64(p.ID)
+}
+
+// ProductCursor 分页游标：上一页最后一条的排序键和 id
+type ProductCursor struct {
+	Key float64
+	ID  int
+}
+
+// ProductQuery 商品列表查询条件。
+// MinPrice/MaxPrice/InStock 作用于型号：至少一个型号满足条件的商品才会返回，
+// 此时列表价格为满足条件的型号中的最低价
+type ProductQuery struct {
+	CategoryIDs []int // 非 nil 时只返回这些分类下的商品
+	MinPrice    *float64
+	MaxPrice    *float64
+	InStock     bool
+	Sort        ProductSort
+	After       *ProductCursor
+	Limit       int
+}
+
+// ProductPage 一页商品，Total 为不分页时的总数，Next 为空表示没有下一页
+type ProductPage struct {
+	Items []ProductSummary
+	Total int
+	Next  *ProductCursor
+}
+
+// ProductSearch 商品搜索条件，Terms 之间为 AND 关系
+type ProductSearch struct {
+	Terms       []string
+	CategoryIDs []int // 非 nil 时只在这些分类中搜索
+	Limit       int
+}
+
+// ProductSearchResult 搜索结果，Rank 越大越相关
+type ProductSearchResult struct {
+	ProductSummary
+	Description string
+	Rank        float64
+}
+
+// Product 商品详情
+type Product struct {
+	ID          int
+	Title       string
+	CategoryID  int
+	Category    string
+	Description string
+	Images      []string
+	Models      []Model
+	ProductStats
+}
+
+// Model 商品型号
+type Model struct {
+	ID        int
+	ProductID int
+	Name      string
+	Price     float64
+	Stock     int
+}
+
+// Review 商品评价
+type Review struct {
+	ID           int
+	ProductID    int
+	Username     string
+	Rating       int
+	Content      string
+	CreatedAt    time.Time
+	ModelID      int
+	OrderItemID  int // 0 表示非购买后评价（历史数据）
+	Status       ReviewStatus
+	HelpfulCount int
+	Images       []string
+}
+
+// ReviewStatus 评价审核状态，只有 approved 的评价对外展示并计入商品评分
+type ReviewStatus string
+
+const (
+	ReviewPending  ReviewStatus = "pending"
+	ReviewApproved ReviewStatus = "approved"
+	ReviewHidden   ReviewStatus = "hidden"
+)
+
+// ReviewSort 评价排序方式
+type ReviewSort string
+
+const (
+	ReviewSortNewest  ReviewSort = "newest"  // 最新在前
+	ReviewSortHelpful ReviewSort = "helpful" // 有用数多的在前，相同时最新在前
+	ReviewSortOldest  ReviewSort = "oldest"  // 最早在前，用于审核队列
+)
+
+// ReviewQuery 评价查询条件
+type ReviewQuery struct {
+	ProductID int // 0 表示全部商品
+	Status    ReviewStatus
+	Rating    int // 0 表示不限星级
+	Sort      ReviewSort
+	Offset    int
+	Limit     int
+}
+
+// ReviewPage 一页评价，Total 为不分页时的总数
+type ReviewPage struct {
+	Items []Review
+	Total int
+}
+
+// NewReview 待写入的购买评价
+type NewReview struct {
+	UserID      int
+	Username    string
+	OrderItemID int
+	Rating      int
+	Content     string
+	Images      []string
+	Status      ReviewStatus // 为 approved 时立即计入商品评分
+}
+
+// ShippingAddress 收货地址内容，也是订单中保存的地址快照
+type ShippingAddress struct {
+	Recipient  string
+	Phone      string
+	Province   string
+	City       string
+	District   string
+	Detail     string
+	PostalCode string
+}
+
+// String 拼接为完整地址，如“广东省 深圳市 南山区 科技园路 1 号”
+func (a ShippingAddress) String() string {
+	var parts []string
+	for _, p := range []string{a.Province, a.City, a.District, a.Detail} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Address 地址簿中的收货地址
+type Address struct {
+	ID     int
+	UserID int
+	ShippingAddress
+	IsDefault bool
+}
+
+// CartItem 购物车项，带商品和型号信息
+type CartItem struct {
+	ID        int
+	ProductID int
+	ModelID   int
+	Quantity  int
+	Title     string
+	Category  string
+	ModelName string
+	Price     float64
+	Img       string
+}
+
+// Order 订单
+type Order struct {
+	ID           int
+	UserID       int
+	Status       orderstate.Status
+	TotalPrice   float64
+	Address      string          // 完整地址文本
+	Shipping     ShippingAddress // 下单时的收货地址快照，仅 GetOrder 填充，早期订单为空
+	CancelReason string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	ItemCount    int
+	Items        []OrderItem
+}
+
+// OrderItem 订单明细。Title、ModelName、Image 为下单时的快照，由 CreateOrder 写入；
+// 商品或型号删除后 ProductID、ModelID 为 0
+type OrderItem struct {
+	ID        int
+	ProductID int
+	ModelID   int
+	Quantity  int
+	Price     float64
+	Title     string
+	ModelName string
+	Image     string
+	Reviewed  bool // 是否已评价，仅 GetOrder 填充
+	// RefundStatus 最近一次退款申请的状态，没有申请时为空，仅 GetOrder 填充
+	RefundStatus RefundStatus
+}
+
+// NewOrder 待写入的订单
+type NewOrder struct {
+	Total float64
+	Items []OrderItem
+}
+
+// OrderBuilder 在下单事务内被调用，models 为已加锁的型号（按 id 索引）。
+// 返回 error 时整个下单事务回滚。
+type OrderBuilder func(models map[int]Model) (*NewOrder, error)
+
+// RefundType 售后类型
+type RefundType string
+
+const (
+	RefundOnly   RefundType = "refund" // 仅退款
+	RefundReturn RefundType = "return" // 退货退款
+)
+
+// RefundStatus 退款申请状态，独立于订单状态
+type RefundStatus string
+
+const (
+	RefundRequested RefundStatus = "requested" // 待处理
+	RefundApproved  RefundStatus = "approved"  // 已同意，等待支付渠道退款
+	RefundRejected  RefundStatus = "rejected"
+	RefundCompleted RefundStatus = "completed" // 已原路退款
+)
+
+// Refund 订单明细的退款/退货申请，一次申请退回整个明细
+type Refund struct {
+	ID               int
+	OrderID          int
+	OrderItemID      int
+	UserID           int
+	Type             RefundType
+	Reason           string
+	ImageKeys        []string // 凭证图片的对象 key，对外展示时换成签名地址
+	Amount           float64  // 明细单价 × 数量
+	Status           RefundStatus
+	ReviewedBy       string
+	ReviewNote       string
+	ProviderRefundID string
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	// 以下为订单明细快照，查询时填充
+	Title     string
+	ModelName string
+	Quantity  int
+}
+
+// RefundQuery 退款申请查询条件，为 0 或空的条件不限制
+type RefundQuery struct {
+	UserID  int
+	OrderID int
+	Status  RefundStatus
+	Oldest  bool // 最早在前（处理队列），否则最新在前
+	Offset  int
+	Limit   int
+}
+
+// RefundPage 一页退款申请，Total 为不分页时的总数
+type RefundPage struct {
+	Items []Refund
+	Total int
+}
+
+// Payment 订单的一次支付，对应支付渠道的一笔交易
+type Payment struct {
+	ID            int
+	OrderID       int
+	Provider      string
+	TransactionID string
+	Amount        float64
+	Status        payment.Status
+	PayURL        string
+	// NeedsRefund 支付成功时订单已不是待付款（已取消或已由其他支付付款），这笔钱需要原路退回
+	NeedsRefund bool
+	Refunded    bool // 需要退回的支付已退回
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// PaymentEvent 支付渠道的一条异步通知
+type PaymentEvent struct {
+	Provider      string
+	EventID       string
+	TransactionID string
+	Status        payment.Status
+	Payload       []byte // 原始请求体，留作对账
+}
+
+// IdempotencyRecord 一个幂等键对应的请求，Completed 为 false 时请求仍在处理中
+type IdempotencyRecord struct {
+	UserID      int
+	Key         string
+	Fingerprint string // 请求内容的摘要，同一 key 只能用于相同的请求
+	Completed   bool
+	StatusCode  int
+	Response    []byte
+	CreatedAt   time.Time
+}
+
+// UserStore 用户存储
+type UserStore interface {
+	GetUser(ctx context.Context, id int) (*User, error)
+	GetUserByUsername(ctx context.Context, username string) (*User, error)
+	// CreateUser 写入新用户并回填 ID，用户名重复时返回 ErrConflict
+	CreateUser(ctx context.Context, u *User) error
+	// UpdatePassword 仅当当前存储的密码仍为 oldHash 时更新，避免覆盖并发修改
+	UpdatePassword(ctx context.Context, id int, oldHash, newHash string) error
+	UpdateAddress(ctx context.Context, id int, address string) error
+	UpdateNickname(ctx context.Context, id int, nickname string) error
+	// UpdateAvatar 修改头像并返回修改前的头像地址
+	UpdateAvatar(ctx context.Context, id int, avatar string) (string, error)
+}
+
+// ProductStore 商品存储
+type ProductStore interface {
+	// ListProducts 按条件分页返回商品
+	ListProducts(ctx context.Context, q ProductQuery) (*ProductPage, error)
+	// SearchProducts 在标题和描述中搜索，按相关度降序返回
+	SearchProducts(ctx context.Context, q ProductSearch) ([]ProductSearchResult, error)
+	// GetProduct 返回商品及其图片和型号
+	GetProduct(ctx context.Context, id int) (*Product, error)
+}
+
+// ReviewStore 评价查询、审核和投票
+type ReviewStore interface {
+	ListReviews(ctx context.Context, q ReviewQuery) (*ReviewPage, error)
+	// SetReviewStatus 变更审核状态，进入或离开 approved 时同步商品评分统计
+	SetReviewStatus(ctx context.Context, reviewID int, status ReviewStatus, actor string) error
+	// VoteHelpful 标记（helpful 为 false 时取消）评价有用，重复操作不报错，返回最新有用数。
+	// 评价不存在或未通过审核时返回 ErrNotFound，给自己的评价投票返回 ErrInvalidState
+	VoteHelpful(ctx context.Context, reviewID, userID int, helpful bool) (int, error)
+}
+
+// CategoryStore 分类存储
+type CategoryStore interface {
+	// ListCategories 按 sort_order、id 返回全部分类
+	ListCategories(ctx context.Context) ([]Category, error)
+}
+
+// AddressStore 收货地址存储，所有操作都限定在 userID 名下，不属于该用户的地址视为不存在。
+// 每个用户至多一个默认地址：设为默认时取消其他地址的默认，用户的第一个地址自动成为默认，
+// 删除默认地址时最近添加的其他地址成为默认
+type AddressStore interface {
+	// ListAddresses 默认地址在前，其余按添加时间倒序
+	ListAddresses(ctx context.Context, userID int) ([]Address, error)
+	GetAddress(ctx context.Context, userID, id int) (*Address, error)
+	// CreateAddress 写入新地址并回填 ID 和 IsDefault；用户已有 limit 个地址时返回 ErrLimitExceeded，
+	// 计数与写入在同一把用户锁内，并发添加也不会超出
+	CreateAddress(ctx context.Context, a *Address, limit int) error
+	// UpdateAddress 修改地址内容，IsDefault 为 true 时同时设为默认，为 false 时不取消默认；回填最新的 IsDefault
+	UpdateAddress(ctx context.Context, a *Address) error
+	DeleteAddress(ctx context.Context, userID, id int) error
+	SetDefaultAddress(ctx context.Context, userID, id int) error
+}
+
+// CartStore 购物车存储，所有操作都限定在 userID 名下
+type CartStore interface {
+	ListCart(ctx context.Context, userID int) ([]CartItem, error)
+	// AddToCart 已有同型号时累加数量并返回 true
+	AddToCart(ctx context.Context, userID, productID, modelID, qty int) (bool, error)
+	UpdateCartQuantity(ctx context.Context, userID, cartID, qty int) error
+	RemoveFromCart(ctx context.Context, userID, cartID int) error
+}
+
+// OrderStore 订单存储
+type OrderStore interface {
+	CountOrdersByStatus(ctx context.Context, userID int) (map[orderstate.Status]int, error)
+	// ListOrders status 为空时返回全部订单
+	ListOrders(ctx context.Context, userID int, status orderstate.Status) ([]Order, error)
+	// GetOrder 返回订单及明细
+	GetOrder(ctx context.Context, userID, orderID int) (*Order, error)
+	OrderHistory(ctx context.Context, orderID int) ([]orderstate.HistoryEntry, error)
+	// CreateOrder 在一个事务内锁定 modelIDs 对应的型号、调用 build 生成订单，
+	// 再按明细扣减库存、写入订单（含收货地址快照）和初始状态记录，返回订单 ID
+	CreateOrder(ctx context.Context, userID int, ship ShippingAddress, actor string, modelIDs []int, build OrderBuilder) (int, error)
+	// TransitionOrder 锁定订单并按 orderstate.Orders 校验后变更状态，写入状态记录
+	TransitionOrder(ctx context.Context, orderID int, to orderstate.Status, actor, note string) error
+	// CancelOrder 取消待付款订单并把明细数量加回库存
+	CancelOrder(ctx context.Context, orderID int, actor, reason string) error
+	// ListPendingOrdersBefore 返回创建时间早于 before 的待付款订单 ID
+	ListPendingOrdersBefore(ctx context.Context, before time.Time, limit int) ([]int, error)
+	// ReviewOrderItem 锁定订单后写入明细的评价并更新商品统计，全部明细评价后订单变为 completed。
+	// 订单或明细不属于该用户时返回 ErrNotFound，订单不在待评价状态时返回 ErrInvalidState，
+	// 明细已评价时返回 ErrConflict
+	ReviewOrderItem(ctx context.Context, orderID int, r NewReview, actor string) (completed bool, err error)
+}
+
+// PaymentStore 支付记录存储
+type PaymentStore interface {
+	// CreatePayment 写入待支付记录并回填 ID 和时间，订单已有待支付记录时返回 ErrConflict
+	CreatePayment(ctx context.Context, p *Payment) error
+	GetPayment(ctx context.Context, id int) (*Payment, error)
+	// LatestPayment 订单最近一次的支付记录，没有时返回 ErrNotFound
+	LatestPayment(ctx context.Context, orderID int) (*Payment, error)
+	// OrderPayment 使订单付款的那笔支付（成功且不需要退回），没有时返回 ErrNotFound
+	OrderPayment(ctx context.Context, orderID int) (*Payment, error)
+	// SettlePayment 锁定待支付记录并更新为最终状态，记录已不是待支付时返回 ErrInvalidState。
+	// 支付成功且订单仍待付款时，在同一事务内把订单流转为待发货并返回 true；
+	// 订单已取消等情况下记录支付成功并标记 NeedsRefund，返回 false
+	SettlePayment(ctx context.Context, id int, status payment.Status, actor string) (orderPaid bool, err error)
+	// ApplyPaymentEvent 在一个事务内记录通知并按 SettlePayment 的规则结算对应的支付。
+	// 同一渠道的同一 EventID 只处理一次，重复时返回 ErrConflict；交易不存在时返回 ErrNotFound 且不记录通知。
+	// 已记为失败的支付收到成功通知时改记为成功并重新结算（订单已不是待付款时标记 NeedsRefund）；
+	// 其他已结算的情况只记录通知，返回 false
+	ApplyPaymentEvent(ctx context.Context, e PaymentEvent, actor string) (orderPaid bool, err error)
+	// ListPaymentsToRefund 标记了 NeedsRefund 但尚未退回的支付，按 ID 先后排列
+	ListPaymentsToRefund(ctx context.Context, limit int) ([]Payment, error)
+	// MarkPaymentRefunded 记录需要退回的支付已原路退回，支付不需要退回或已退回时返回 ErrInvalidState
+	MarkPaymentRefunded(ctx context.Context, id int, providerRefundID string) error
+}
+
+// RefundStore 退款申请存储
+type RefundStore interface {
+	// CreateRefund 锁定订单后为明细写入退款申请，回填 ID、金额、状态和明细快照。
+	// 订单或明细不属于 r.UserID（或商品已删除）时返回 ErrNotFound，订单状态不在 allowed 中时返回 ErrInvalidState，
+	// 明细已有未被拒绝的申请（包括已退款）时返回 ErrConflict
+	CreateRefund(ctx context.Context, r *Refund, allowed []orderstate.Status) error
+	GetRefund(ctx context.Context, id int) (*Refund, error)
+	ListRefunds(ctx context.Context, q RefundQuery) (*RefundPage, error)
+	// RejectRefund 拒绝待处理的申请，申请已处理时返回 ErrInvalidState
+	RejectRefund(ctx context.Context, id int, actor, note string) error
+	// ApproveRefund 同意待处理的申请。退货退款或订单尚未发货时把明细数量加回库存（型号已删除时跳过），
+	// 已发货后的仅退款不归还库存。
+	// 申请已同意时原样返回，不重复归还库存；已拒绝或已完成时返回 ErrInvalidState
+	ApproveRefund(ctx context.Context, id int, actor, note string) (*Refund, error)
+	// CompleteRefund 记录渠道退款单号，把已同意的申请标记为已退款，并从商品销量中扣除该明细。
+	// 订单全部明细都已退款时订单流转为 refund 并返回 true（已完成的订单不再流转）；待评价订单剩余明细均已评价时流转为 completed。
+	// 申请不是 approved 时返回 ErrInvalidState
+	CompleteRefund(ctx context.Context, id int, providerRefundID, actor string) (orderRefunded bool, err error)
+}
+
+// IdempotencyStore 幂等键存储，key 在同一用户内唯一
+type IdempotencyStore interface {
+	// ReserveIdempotencyKey 占用 key：不存在、已有记录创建于 expiredBefore 之前，或已有记录仍在处理中且创建于
+	// staleBefore 之前（原请求已中断）时写入处理中的记录并返回 nil，否则返回已有记录
+	ReserveIdempotencyKey(ctx context.Context, userID int, key, fingerprint string, expiredBefore, staleBefore time.Time) (*IdempotencyRecord, error)
+	// CompleteIdempotencyKey 保存请求的响应
+	CompleteIdempotencyKey(ctx context.Context, userID int, key string, statusCode int, response []byte) error
+	// ReleaseIdempotencyKey 删除处理中的记录，之后同一 key 可以重新提交
+	ReleaseIdempotencyKey(ctx context.Context, userID int, key string) error
+	// DeleteIdempotencyKeysBefore 删除创建于 before 之前的记录，返回删除的数量
+	DeleteIdempotencyKeysBefore(ctx context.Context, before time.Time, limit int) (int, error)
+}
+
+// Stores 全部存储
+type Stores struct {
+	Users       UserStore
+	Products    ProductStore
+	Categories  CategoryStore
+	Reviews     ReviewStore
+	Addresses   AddressStore
+	Carts       CartStore
+	Orders      OrderStore
+	Payments    PaymentStore
+	Refunds     RefundStore
+	Idempotency IdempotencyStore
+}
@@ -0,0 +1,17 @@
+package worker
+
+import (
+	"back/service"
+	"context"
+	"time"
+)
+
+// 每轮最多删除的幂等键数
+const idempotencyCleanupBatchSize = 1000
+
+// StartIdempotencyCleanup 定期删除超过保留时间的幂等键，退出语义见 runEvery
+func StartIdempotencyCleanup(ctx context.Context, keys *service.IdempotencyService, retention, interval time.Duration) <-chan struct{} {
+	return runEvery(ctx, interval, "清理过期幂等键", func(ctx context.Context) (int, error) {
+		return keys.PurgeExpired(ctx, retention, idempotencyCleanupBatchSize)
+	})
+}
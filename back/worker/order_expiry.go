@@ -0,0 +1,20 @@
+package worker
+
+import (
+	"back/service"
+	"context"
+	"time"
+)
+
+// 超时未支付订单的取消原因
+const expiredCancelReason = "超时未支付，系统自动取消"
+
+// 每轮最多处理的订单数
+const expiryBatchSize = 100
+
+// StartOrderExpiry 定期取消创建时间超过 timeout 仍未支付的订单并归还库存，退出语义见 runEvery
+func StartOrderExpiry(ctx context.Context, orders *service.OrderService, timeout, interval time.Duration) <-chan struct{} {
+	return runEvery(ctx, interval, "取消超时未支付订单", func(ctx context.Context) (int, error) {
+		return orders.ExpirePending(ctx, timeout, expiryBatchSize, expiredCancelReason)
+	})
+}
@@ -0,0 +1,17 @@
+package worker
+
+import (
+	"back/service"
+	"context"
+	"time"
+)
+
+// 每轮最多退回的支付数
+const paymentRefundBatchSize = 100
+
+// StartPaymentRefunds 定期把支付成功但订单未因此付款（如订单已超时取消）的支付原路退回，退出语义见 runEvery
+func StartPaymentRefunds(ctx context.Context, payments *service.PaymentService, interval time.Duration) <-chan struct{} {
+	return runEvery(ctx, interval, "退回订单已取消的支付", func(ctx context.Context) (int, error) {
+		return payments.RefundUnmatched(ctx, paymentRefundBatchSize)
+	})
+}
@@ -0,0 +1,33 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// runEvery 启动后台协程，立即执行一次 fn，之后每隔 interval 执行一次；fn 返回本轮处理的数量，name 用于日志。
+// ctx 会传给 fn，取消时正在进行的一轮随之中断（已处理的记录不受影响，其余的下次启动后继续处理），
+// 协程随即退出并关闭返回的 channel
+func runEvery(ctx context.Context, interval time.Duration, name string, fn func(ctx context.Context) (int, error)) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			n, err := fn(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("%s失败: %v", name, err)
+			} else if n > 0 {
+				log.Printf("%s: 本轮处理 %d 条", name, n)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return done
+}
@@ -0,0 +1,39 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunEvery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var rounds int32
+	started := make(chan struct{})
+	done := runEvery(ctx, time.Millisecond, "测试任务", func(ctx context.Context) (int, error) {
+		if atomic.AddInt32(&rounds, 1) == 3 {
+			close(started)
+			// 取消会中断正在进行的一轮
+			<-ctx.Done()
+			return 0, ctx.Err()
+		}
+		return 1, errors.New("失败的一轮不影响下一轮")
+	})
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("job did not repeat")
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("done not closed after cancel")
+	}
+	if n := atomic.LoadInt32(&rounds); n != 3 {
+		t.Fatalf("rounds = %d, want 3", n)
+	}
+}
@@ -78,21 +78,12 @@ const Checkout = () => {
       quantity: item.qty,
       price: item.price
     }));
-    // 获取用户地址（可从用户信息store获取）
-    let address = '';
-    try {
-      const res = await fetch('/api/user/address', { credentials: 'include' });
-      const data = await res.json();
-      if (data && data.address) address = data.address;
-    } catch {
-      //
-    }
-    // 提交订单到后端
+    // 提交订单到后端，不传 address_id 时使用地址簿中的默认地址
     const res = await fetch('/api/order/create', {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       credentials: 'include',
-      body: JSON.stringify({ items, address, total }),
+      body: JSON.stringify({ items, total }),
     });
     setLoading(false);
     if (res.ok) {
@@ -110,7 +101,8 @@ const Checkout = () => {
         navigate('/order/pending');
       }
     } else {
-      alert('下单失败');
+      const data = await res.json().catch(() => ({}));
+      alert(data.error || '下单失败');
     }
   };
 
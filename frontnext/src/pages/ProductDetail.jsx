@@ -127,15 +127,6 @@ const ProductDetail = () => {
       alert('型号信息有误');
       return;
     }
-    // 获取用户地址
-    let address = '';
-    try {
-      const res = await fetch('/api/user/address', { credentials: 'include' });
-      const data = await res.json();
-      if (data && data.address) address = data.address;
-    } catch {
-      //
-    }
     // 构造下单数据
     const items = [{
       product_id: product.id,
@@ -144,13 +135,13 @@ const ProductDetail = () => {
       price: modelObj.price
     }];
     const total = modelObj.price * qty;
-    // 提交订单
+    // 提交订单，不传 address_id 时使用地址簿中的默认地址
     try {
       const res = await fetch('/api/order/create', {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
         credentials: 'include',
-        body: JSON.stringify({ items, address, total })
+        body: JSON.stringify({ items, total })
       });
       const data = await res.json();
       if (res.ok && data.order_id) {